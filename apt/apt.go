@@ -1,7 +1,6 @@
 package apt
 
 import (
-	"math/rand"
 	"reflect"
 )
 
@@ -25,18 +24,9 @@ type Node interface {
 // CopyTree copies a tree (or subtree) and returns the copy.
 // Since Node is an interface, we need to use reflection in CopyTree
 func CopyTree(node, parent Node) Node {
-	nodeCopy := reflect.New(reflect.ValueOf(node).Elem().Type()).Interface().(Node)
-
-	// Make sure that constants have their value copied
-	switch n := node.(type) {
-	case *OperatorConstant:
-		nodeCopy.(*OperatorConstant).Value = n.Value
-	}
-
-	nodeCopy.SetParent(parent)
-	copyChildren := make([]Node, len(node.GetChildren()))
-	nodeCopy.SetChildren(copyChildren)
+	nodeCopy := copyNode(node, parent)
 
+	copyChildren := nodeCopy.GetChildren()
 	for i := range copyChildren {
 		copyChildren[i] = CopyTree(node.GetChildren()[i], nodeCopy)
 	}
@@ -44,6 +34,21 @@ func CopyTree(node, parent Node) Node {
 	return nodeCopy
 }
 
+// copyNode copies a single node, leaving its children slice empty (nil entries).
+func copyNode(node, parent Node) Node {
+	value := reflect.New(reflect.ValueOf(node).Elem().Type())
+
+	// Copy all fields, so that for example the value of a constant
+	// and the spec of an OperatorFunc are kept
+	value.Elem().Set(reflect.ValueOf(node).Elem())
+	nodeCopy := value.Interface().(Node)
+
+	nodeCopy.SetParent(parent)
+	nodeCopy.SetChildren(make([]Node, len(node.GetChildren())))
+
+	return nodeCopy
+}
+
 func ReplaceNode(old, new Node) {
 	oldParent := old.GetParent()
 
@@ -75,108 +80,109 @@ func GetNthNode(node Node, n, count int) (Node, int) {
 }
 
 func Mutate(node Node) Node {
-	r := rand.Intn(23)
+	return defaultGenerator.Mutate(node)
+}
+
+// Mutate replaces node with a random operator or leaf, that keeps as many of
+// the children of node as it can use. The mutated node is returned.
+func (g *Generator) Mutate(node Node) Node {
+	r := g.rand.Intn(23)
 	var mutatedNode Node
 
 	if r <= 19 {
-		mutatedNode = GetRandomNode()
+		mutatedNode = g.RandomNode()
 	} else {
-		mutatedNode = GetRandomLeafNode()
+		mutatedNode = g.RandomLeafNode()
+	}
+	g.replace(node, mutatedNode)
+	return mutatedNode
+}
+
+// Replace replaces node with a new node of the named operator, that keeps
+// as many of the children of node as it can use, like Mutate does. The
+// new node is returned.
+func (g *Generator) Replace(node Node, name string) (Node, error) {
+	newNode, err := NewNode(name)
+	if err != nil {
+		return nil, err
 	}
+	g.replace(node, newNode)
+	return newNode, nil
+}
 
+// replace puts newNode in the place of node, and gives it the children
+// of node. Any children that are left are filled with random leaves.
+func (g *Generator) replace(node, newNode Node) {
 	// Fix parents child pointer
 	if node.GetParent() != nil {
 		for i, parentChild := range node.GetParent().GetChildren() {
 			if parentChild == node {
-				node.GetParent().GetChildren()[i] = mutatedNode
+				node.GetParent().GetChildren()[i] = newNode
 			}
 		}
 	}
 
-	// Add children from the old node to the mutated node
+	// Add children from the old node to the new node
 	for i, child := range node.GetChildren() {
-		if i >= len(mutatedNode.GetChildren()) {
+		if i >= len(newNode.GetChildren()) {
 			break
 		}
-		mutatedNode.GetChildren()[i] = child
-		child.SetParent(mutatedNode)
+		newNode.GetChildren()[i] = child
+		child.SetParent(newNode)
 	}
 
 	// Any nil children are filled with random leafs
-	for i, child := range mutatedNode.GetChildren() {
+	for i, child := range newNode.GetChildren() {
 		if child == nil {
-			leaf := GetRandomLeafNode()
-			leaf.SetParent(mutatedNode)
-			mutatedNode.GetChildren()[i] = leaf
+			leaf := g.RandomLeafNode()
+			leaf.SetParent(newNode)
+			newNode.GetChildren()[i] = leaf
 		}
 	}
 
-	mutatedNode.SetParent(node.GetParent())
-
-	return mutatedNode
+	newNode.SetParent(node.GetParent())
 }
 
+// GetRandomNode returns a random enabled operator from the registry,
+// picked in proportion to the operator weights.
 func GetRandomNode() Node {
-	r := rand.Intn(21)
-	switch r {
-	case 0:
-		return NewPlus()
-	case 1:
-		return NewMinus()
-	case 2:
-		return NewMult()
-	case 3:
-		return NewDiv()
-	case 4:
-		return NewAtan2()
-	case 5:
-		return NewAtan()
-	case 6:
-		return NewCos()
-	case 7:
-		return NewSin()
-	case 8:
-		return NewNoise()
-	case 9:
-		return NewSquare()
-	case 10:
-		return NewLog2()
-	case 11:
-		return NewNegate()
-	case 12:
-		return NewCeil()
-	case 13:
-		return NewFloor()
-	case 14:
-		return NewAbs()
-	case 15:
-		return NewClip()
-	case 16:
-		return NewWrap()
-	case 17:
-		return NewLerp()
-	case 18:
-		return NewFBM()
-	case 19:
-		return NewTurbulence()
-	case 20:
-		return NewSwirl()
-
-	default:
-		panic("GetRandomNode failed")
-	}
+	return defaultGenerator.RandomNode()
 }
 
+// GetRandomLeafNode returns a random enabled leaf from the registry,
+// picked in proportion to the leaf weights.
 func GetRandomLeafNode() Node {
-	r := rand.Intn(3)
-	switch r {
-	case 0:
-		return NewX()
-	case 1:
-		return NewY()
-	case 2:
-		return NewConstant()
-	default:
-		panic("GetRandomBaseNode failed")
+	return defaultGenerator.RandomLeafNode()
+}
+
+// Depth returns the number of levels in the tree, a single leaf has depth 1.
+func Depth(node Node) int {
+	depth := 0
+	for _, child := range node.GetChildren() {
+		depth = max(depth, Depth(child))
+	}
+	return depth + 1
+}
+
+// Prune repairs a tree that is deeper than maxDepth, by replacing every
+// node at the deepest allowed level with a random leaf. The (possibly new)
+// root of the tree is returned.
+func Prune(node Node, maxDepth int) Node {
+	return defaultGenerator.Prune(node, maxDepth)
+}
+
+// Prune works like the package level Prune, using the generator for the leaves.
+func (g *Generator) Prune(node Node, maxDepth int) Node {
+	if len(node.GetChildren()) == 0 {
+		return node
+	}
+	if maxDepth <= 1 {
+		leaf := g.RandomLeafNode()
+		ReplaceNode(node, leaf)
+		return leaf
+	}
+	for _, child := range node.GetChildren() {
+		g.Prune(child, maxDepth-1)
 	}
+	return node
 }
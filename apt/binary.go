@@ -0,0 +1,197 @@
+package apt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The binary format stores a list of trees compactly :
+//
+//	magic     "APTB"
+//	version   1 byte, BinaryVersion
+//	names     uvarint count, then for every name a uvarint length and the bytes
+//	trees     uvarint count, then every tree in pre-order
+//
+// Every node of a tree is a uvarint opcode, an index into the names. Its
+// children follow it, and a constant (named "Constant") is followed by its
+// value as a little-endian float64. Only the operators used are listed in
+// the names, in the order in which they first appear, so the opcodes do not
+// depend on the registry. Since constants are stored exactly, decoding an
+// encoded tree gives a tree with the same String() as the original.
+
+// BinaryVersion is the version of the binary format written by EncodeBinary.
+const BinaryVersion = 1
+
+const binaryMagic = "APTB"
+
+const (
+	// maxNameLength and maxBinaryDepth protect the decoder against corrupt input.
+	maxNameLength  = 256
+	maxBinaryDepth = 10000
+)
+
+// IsBinary returns true if data starts like the binary format.
+func IsBinary(data []byte) bool {
+	return len(data) >= len(binaryMagic) && string(data[:len(binaryMagic)]) == binaryMagic
+}
+
+// EncodeBinary writes the trees to w in the binary format.
+func EncodeBinary(w io.Writer, trees []Node) error {
+	opcodes := make(map[string]uint64)
+	var names []string
+	for _, tree := range trees {
+		for _, node := range Nodes(tree) {
+			name := binaryName(node)
+			if _, ok := opcodes[name]; !ok {
+				opcodes[name] = uint64(len(names))
+				names = append(names, name)
+			}
+		}
+	}
+
+	buf := []byte(binaryMagic)
+	buf = append(buf, BinaryVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(names)))
+	for _, name := range names {
+		buf = binary.AppendUvarint(buf, uint64(len(name)))
+		buf = append(buf, name...)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(trees)))
+	for _, tree := range trees {
+		buf = appendBinary(buf, tree, opcodes)
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendBinary(buf []byte, node Node, opcodes map[string]uint64) []byte {
+	buf = binary.AppendUvarint(buf, opcodes[binaryName(node)])
+	if c, ok := node.(*OperatorConstant); ok {
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.Value))
+	}
+	for _, child := range node.GetChildren() {
+		buf = appendBinary(buf, child, opcodes)
+	}
+	return buf
+}
+
+func binaryName(node Node) string {
+	if _, ok := node.(*OperatorConstant); ok {
+		return constantName
+	}
+	return NodeName(node)
+}
+
+// DecodeBinary reads trees in the binary format from r.
+func DecodeBinary(r io.Reader) ([]Node, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		buffered := bufio.NewReader(r)
+		r, br = buffered, buffered
+	}
+
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("binary header : %w", unexpectedEOF(err))
+	}
+	if !IsBinary(header) {
+		return nil, errors.New("not a binary .apt file")
+	}
+	if version := header[len(binaryMagic)]; version != BinaryVersion {
+		return nil, fmt.Errorf("unsupported binary version %d", version)
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var names []string
+	for i := uint64(0); i < count; i++ {
+		length, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if length > maxNameLength {
+			return nil, fmt.Errorf("operator name of %d bytes is too long", length)
+		}
+		name := make([]byte, length)
+		if _, err = io.ReadFull(r, name); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		names = append(names, string(name))
+	}
+
+	for _, name := range names {
+		if _, err = stringToNode(name); err != nil && name != constantName {
+			return nil, err
+		}
+	}
+
+	d := binaryDecoder{r: r, br: br, names: names}
+
+	if count, err = binary.ReadUvarint(br); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var trees []Node
+	for i := uint64(0); i < count; i++ {
+		tree, err := d.decode(nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("tree %d : %w", i+1, err)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
+
+type binaryDecoder struct {
+	r     io.Reader
+	br    io.ByteReader
+	names []string
+}
+
+func (d *binaryDecoder) decode(parent Node, depth int) (Node, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("tree is too deep")
+	}
+	opcode, err := binary.ReadUvarint(d.br)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if opcode >= uint64(len(d.names)) {
+		return nil, fmt.Errorf("invalid opcode %d", opcode)
+	}
+
+	name := d.names[opcode]
+	if name == constantName {
+		var value [8]byte
+		if _, err = io.ReadFull(d.r, value[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		c := NewConstant()
+		c.Value = math.Float64frombits(binary.LittleEndian.Uint64(value[:]))
+		c.SetParent(parent)
+		return c, nil
+	}
+
+	node, _ := stringToNode(name)
+	node.SetParent(parent)
+	children := node.GetChildren()
+	for i := range children {
+		if children[i], err = d.decode(node, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package apt
+
+// Nodes returns all nodes in the tree in pre-order, which is
+// the same order that GetNthNode counts them in.
+func Nodes(node Node) []Node {
+	nodes := []Node{node}
+	for _, child := range node.GetChildren() {
+		nodes = append(nodes, Nodes(child)...)
+	}
+	return nodes
+}
+
+// UniformCrossover returns a new tree created by walking a and b in parallel
+// from the root. Inside the common region (where both trees have the same
+// shape) each node is taken from either parent with equal probability,
+// keeping the children in place. Where the shapes differ, the whole subtree
+// is taken from either parent.
+func UniformCrossover(a, b Node) Node {
+	return defaultGenerator.UniformCrossover(a, b)
+}
+
+// UniformCrossover works like the package level UniformCrossover, using the generator.
+func (g *Generator) UniformCrossover(a, b Node) Node {
+	return g.uniformCross(a, b, nil)
+}
+
+func (g *Generator) uniformCross(a, b, parent Node) Node {
+	aChildren := a.GetChildren()
+	bChildren := b.GetChildren()
+
+	if len(aChildren) != len(bChildren) {
+		// Boundary of the common region
+		if g.rand.Intn(2) == 0 {
+			return CopyTree(a, parent)
+		}
+		return CopyTree(b, parent)
+	}
+
+	source := a
+	if g.rand.Intn(2) == 1 {
+		source = b
+	}
+	node := copyNode(source, parent)
+	for i := range node.GetChildren() {
+		node.GetChildren()[i] = g.uniformCross(aChildren[i], bChildren[i], node)
+	}
+	return node
+}
+
+// SizeFairDonor picks a random subtree of donor that is no larger than
+// 1 + 2*size nodes, which is the limit used by Langdon's size fair crossover.
+// Leaves always qualify, so a subtree is always returned.
+func SizeFairDonor(donor Node, size int) Node {
+	return defaultGenerator.SizeFairDonor(donor, size)
+}
+
+// SizeFairDonor works like the package level SizeFairDonor, using the generator.
+func (g *Generator) SizeFairDonor(donor Node, size int) Node {
+	limit := 1 + 2*size
+	candidates := make([]Node, 0)
+	for _, node := range Nodes(donor) {
+		if node.NodeCount() <= limit {
+			candidates = append(candidates, node)
+		}
+	}
+	return candidates[g.rand.Intn(len(candidates))]
+}
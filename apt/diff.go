@@ -0,0 +1,279 @@
+package apt
+
+import (
+	"fmt"
+	"slices"
+)
+
+// EditKind is the kind of change made by an Edit.
+type EditKind int
+
+const (
+	// EditConstant changes the value of constant A to the value of B.
+	EditConstant EditKind = iota
+	// EditOperator changes the operator of A to the one of B. The children
+	// are kept in place, as Mutate does, and the children that are left
+	// over are removed or inserted by edits of their own.
+	EditOperator
+	// EditInsert inserts B. If A is nil, B is a new subtree. Otherwise B is
+	// inserted above A, which becomes child number Child of B, and the
+	// other children of B are new.
+	EditInsert
+	// EditRemove removes A. If B is nil, the whole subtree is removed.
+	// Otherwise only A and its children other than number Child are
+	// removed, and child number Child takes the place of A, becoming B.
+	EditRemove
+	// EditReplace replaces the subtree A with the subtree B, which has no
+	// nodes in common with it.
+	EditReplace
+)
+
+var editKindNames = []string{"constant", "operator", "insert", "remove", "replace"}
+
+func (k EditKind) String() string {
+	if k < 0 || int(k) >= len(editKindNames) {
+		return fmt.Sprintf("EditKind(%d)", int(k))
+	}
+	return editKindNames[k]
+}
+
+// Edit is a change in an edit script, see Diff.
+type Edit struct {
+	Kind EditKind
+	// A is the node in the first tree, and B is the node in the second
+	// tree. A is nil for a new subtree, and B is nil for a removed subtree.
+	A, B Node
+	// Path is the index of each child on the way from the root of the first
+	// tree to A. For a new subtree, it leads to the place where B is added.
+	Path []int
+	// Child is the index of the child that is kept, when a node is
+	// inserted or removed above it.
+	Child int
+}
+
+// String describes the change, without the path.
+func (e Edit) String() string {
+	switch e.Kind {
+	case EditConstant:
+		return fmt.Sprintf("constant %s -> %s", e.A, e.B)
+	case EditOperator:
+		return fmt.Sprintf("operator %s -> %s", NodeName(e.A), NodeName(e.B))
+	case EditInsert:
+		if e.A == nil {
+			return "insert " + e.B.String()
+		}
+		return fmt.Sprintf("insert %s above %s", withHole(e.B, e.Child), NodeName(e.A))
+	case EditRemove:
+		if e.B == nil {
+			return "remove " + e.A.String()
+		}
+		return "remove " + withHole(e.A, e.Child)
+	case EditReplace:
+		return fmt.Sprintf("replace %s with %s", e.A, e.B)
+	default:
+		return e.Kind.String()
+	}
+}
+
+// withHole returns node as text, with "_" in place of the given child.
+func withHole(node Node, child int) string {
+	s := "( " + NodeName(node)
+	for i, c := range node.GetChildren() {
+		if i == child {
+			s += " _"
+		} else {
+			s += " " + c.String()
+		}
+	}
+	return s + " )"
+}
+
+// Diff returns an edit script that changes tree a into tree b, with the
+// edits in pre-order. The script keeps as many nodes of a as it can, and
+// changes constants and operators in place rather than replacing them.
+// Nodes are compared by operator and value only, so swapping the children
+// of a commutative operator is a change. Trees that are equal give an
+// empty script. The channels of Picture nodes are only compared with each
+// other.
+func Diff(a, b Node) []Edit {
+	d := differ{
+		sizes: make(map[Node]int),
+		memo:  make(map[[2]Node]diffMatch),
+	}
+	d.count(a)
+	d.count(b)
+	return d.edits(a, b, nil, nil)
+}
+
+// diffStep is the change that matches two nodes in the cheapest script.
+type diffStep int
+
+const (
+	stepKeep diffStep = iota
+	stepConstant
+	stepOperator
+	stepInsert
+	stepRemove
+)
+
+// diffMatch is the cheapest way found to change one subtree into another.
+type diffMatch struct {
+	cost  int // inserted and removed nodes, plus changed constants and operators
+	kept  int // nodes that are not changed
+	step  diffStep
+	child int // for stepInsert and stepRemove
+}
+
+// better returns true if m is a cheaper script than other, or one that
+// keeps more nodes at the same cost.
+func (m diffMatch) better(other diffMatch) bool {
+	if m.cost != other.cost {
+		return m.cost < other.cost
+	}
+	return m.kept > other.kept
+}
+
+type differ struct {
+	sizes map[Node]int
+	memo  map[[2]Node]diffMatch
+}
+
+// count remembers the size of every subtree of node.
+func (d *differ) count(node Node) int {
+	size := 1
+	for _, child := range node.GetChildren() {
+		size += d.count(child)
+	}
+	d.sizes[node] = size
+	return size
+}
+
+// match returns the cheapest way to change a into b.
+func (d *differ) match(a, b Node) diffMatch {
+	key := [2]Node{a, b}
+	if m, ok := d.memo[key]; ok {
+		return m
+	}
+
+	_, aIsPicture := a.(*OperatorPicture)
+	_, bIsPicture := b.(*OperatorPicture)
+	aConstant, aIsConstant := a.(*OperatorConstant)
+	var best diffMatch
+	switch {
+	case aIsPicture || bIsPicture:
+		best = d.matchChildren(a, b, stepKeep)
+	case NodeName(a) != NodeName(b):
+		best = d.matchChildren(a, b, stepOperator)
+	case aIsConstant:
+		best = diffMatch{kept: 1}
+		if !sameConstant(aConstant.Value, b.(*OperatorConstant).Value) {
+			best = diffMatch{cost: 1, step: stepConstant}
+		}
+	default:
+		best = d.matchChildren(a, b, stepKeep)
+	}
+
+	if !aIsPicture && !bIsPicture {
+		for i, child := range b.GetChildren() {
+			m := d.match(a, child)
+			m = diffMatch{cost: m.cost + d.sizes[b] - d.sizes[child], kept: m.kept, step: stepInsert, child: i}
+			if m.better(best) {
+				best = m
+			}
+		}
+		for i, child := range a.GetChildren() {
+			m := d.match(child, b)
+			m = diffMatch{cost: m.cost + d.sizes[a] - d.sizes[child], kept: m.kept, step: stepRemove, child: i}
+			if m.better(best) {
+				best = m
+			}
+		}
+	}
+
+	d.memo[key] = best
+	return best
+}
+
+// matchChildren matches the children of a and b in place. The children
+// that are left over are removed or inserted.
+func (d *differ) matchChildren(a, b Node, step diffStep) diffMatch {
+	m := diffMatch{step: step}
+	if step == stepKeep {
+		m.kept = 1
+	} else {
+		m.cost = 1
+	}
+	aChildren, bChildren := a.GetChildren(), b.GetChildren()
+	for i := range max(len(aChildren), len(bChildren)) {
+		switch {
+		case i >= len(bChildren):
+			m.cost += d.sizes[aChildren[i]]
+		case i >= len(aChildren):
+			m.cost += d.sizes[bChildren[i]]
+		default:
+			child := d.match(aChildren[i], bChildren[i])
+			m.cost += child.cost
+			m.kept += child.kept
+		}
+	}
+	return m
+}
+
+// Equal returns true if a and b have the same structure and exactly the
+// same constants, which is when Diff gives an empty script.
+func Equal(a, b Node) bool {
+	if NodeName(a) != NodeName(b) {
+		return false
+	}
+	if ca, ok := a.(*OperatorConstant); ok {
+		return sameConstant(ca.Value, b.(*OperatorConstant).Value)
+	}
+	for i, child := range a.GetChildren() {
+		if !Equal(child, b.GetChildren()[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameConstant returns true if the values print the same in an .apt file.
+func sameConstant(a, b float64) bool {
+	return a == b || (a != a && b != b)
+}
+
+// edits appends the script that changes a into b to edits, where path leads to a.
+func (d *differ) edits(a, b Node, path []int, edits []Edit) []Edit {
+	m := d.match(a, b)
+	switch m.step {
+	case stepConstant:
+		return append(edits, Edit{Kind: EditConstant, A: a, B: b, Path: path})
+	case stepOperator:
+		if m.kept == 0 {
+			return append(edits, Edit{Kind: EditReplace, A: a, B: b, Path: path})
+		}
+		edits = append(edits, Edit{Kind: EditOperator, A: a, B: b, Path: path})
+	case stepInsert:
+		edits = append(edits, Edit{Kind: EditInsert, A: a, B: b, Path: path, Child: m.child})
+		return d.edits(a, b.GetChildren()[m.child], path, edits)
+	case stepRemove:
+		edits = append(edits, Edit{Kind: EditRemove, A: a, B: b, Path: path, Child: m.child})
+		return d.edits(a.GetChildren()[m.child], b, childPath(path, m.child), edits)
+	}
+
+	aChildren, bChildren := a.GetChildren(), b.GetChildren()
+	for i := range max(len(aChildren), len(bChildren)) {
+		switch {
+		case i >= len(bChildren):
+			edits = append(edits, Edit{Kind: EditRemove, A: aChildren[i], Path: childPath(path, i)})
+		case i >= len(aChildren):
+			edits = append(edits, Edit{Kind: EditInsert, B: bChildren[i], Path: childPath(path, i)})
+		default:
+			edits = d.edits(aChildren[i], bChildren[i], childPath(path, i), edits)
+		}
+	}
+	return edits
+}
+
+func childPath(path []int, i int) []int {
+	return append(slices.Clone(path), i)
+}
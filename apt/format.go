@@ -0,0 +1,89 @@
+package apt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FormatOptions control the layout of Format.
+type FormatOptions struct {
+	// Width is the longest line wanted. Subtrees that fit in the width
+	// are kept on one line, others are split with one child per line.
+	// Leaves are never split, so lines can still be longer.
+	Width int
+	// Indent is added in front of the children of a split subtree.
+	Indent string
+	// Precision is the number of digits after the decimal point of the
+	// constants, or -1 for as many as needed to read back the same value.
+	Precision int
+}
+
+// DefaultFormatOptions are the options used when pictures are saved. The
+// precision is the one of OperatorConstant.String.
+var DefaultFormatOptions = FormatOptions{
+	Width:     80,
+	Indent:    "  ",
+	Precision: 9,
+}
+
+// Format returns the canonical text of a tree, as read by the parser,
+// indented according to options and ending with a newline. A Picture node
+// always has its channels on lines of their own.
+func Format(node Node, options FormatOptions) string {
+	f := formatter{options: options}
+	f.format(node, "")
+	return f.sb.String()
+}
+
+type formatter struct {
+	options FormatOptions
+	sb      strings.Builder
+}
+
+func (f *formatter) format(node Node, indent string) {
+	if len(node.GetChildren()) == 0 {
+		f.sb.WriteString(indent + f.leaf(node) + "\n")
+		return
+	}
+	if _, isPicture := node.(*OperatorPicture); !isPicture {
+		var line strings.Builder
+		if f.line(&line, node, f.options.Width-len(indent)) {
+			f.sb.WriteString(indent + line.String() + "\n")
+			return
+		}
+	}
+
+	f.sb.WriteString(indent + "( " + NodeName(node) + "\n")
+	for _, child := range node.GetChildren() {
+		f.format(child, indent+f.options.Indent)
+	}
+	f.sb.WriteString(indent + ")\n")
+}
+
+// line writes node on a single line to sb, and reports whether the line
+// is at most width long. It stops as soon as the line is too long, so
+// that trying every split subtree doesn't take quadratic time.
+func (f *formatter) line(sb *strings.Builder, node Node, width int) bool {
+	if len(node.GetChildren()) == 0 {
+		sb.WriteString(f.leaf(node))
+		return sb.Len() <= width
+	}
+
+	sb.WriteString("( " + NodeName(node))
+	for _, child := range node.GetChildren() {
+		sb.WriteString(" ")
+		if sb.Len() > width || !f.line(sb, child, width) {
+			return false
+		}
+	}
+	sb.WriteString(" )")
+	return sb.Len() <= width
+}
+
+// leaf returns a node without children.
+func (f *formatter) leaf(node Node) string {
+	if c, ok := node.(*OperatorConstant); ok {
+		return strconv.FormatFloat(c.Value, 'f', f.options.Precision, 64)
+	}
+	return NodeName(node)
+}
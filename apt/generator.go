@@ -0,0 +1,91 @@
+package apt
+
+import "math/rand"
+
+// Source is the random number generator used by a Generator.
+type Source interface {
+	Intn(n int) int
+	Float64() float64
+}
+
+// globalSource uses the top-level functions of math/rand,
+// which are safe for concurrent use.
+type globalSource struct{}
+
+func (globalSource) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+func (globalSource) Float64() float64 {
+	return rand.Float64()
+}
+
+// Generator creates and changes random trees, using its own random source
+// and (optionally) its own operator weights. A Generator created by
+// NewGenerator is not safe for concurrent use, but separate generators can
+// be used concurrently, which for example allows every island in an island
+// model to have its own seed. The package level functions (GetRandomNode,
+// Mutate, Grow...) use a default generator that is safe for concurrent use.
+type Generator struct {
+	rand    Source
+	weights map[string]float64
+}
+
+var defaultGenerator = &Generator{rand: globalSource{}}
+
+// NewGenerator creates a generator with its own random source, seeded with seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// DefaultGenerator returns the generator used by the package level functions.
+func DefaultGenerator() *Generator {
+	return defaultGenerator
+}
+
+// Rand returns the random source of the generator.
+func (g *Generator) Rand() Source {
+	return g.rand
+}
+
+// SetWeight overrides the registry weight of the named operator for this
+// generator only. A weight of zero stops the generator from picking it.
+func (g *Generator) SetWeight(name string, weight float64) {
+	if g.weights == nil {
+		g.weights = make(map[string]float64)
+	}
+	g.weights[name] = weight
+}
+
+// weight returns the weight of an operator for this generator, or 0 if it is disabled.
+func (g *Generator) weight(spec *OperatorSpec) float64 {
+	if !spec.Enabled {
+		return 0
+	}
+	if w, ok := g.weights[spec.Name]; ok {
+		return w
+	}
+	return spec.Weight
+}
+
+// RandomNode returns a random enabled operator, picked in proportion to the operator weights.
+func (g *Generator) RandomNode() Node {
+	return g.pickRandom(false)
+}
+
+// RandomLeafNode returns a random enabled leaf, picked in proportion to the leaf weights.
+func (g *Generator) RandomLeafNode() Node {
+	return g.pickRandom(true)
+}
+
+// AddRandom adds node as a child of a random descendant of parent, that has an empty child slot.
+func (g *Generator) AddRandom(parent, node Node) {
+	children := parent.GetChildren()
+	addIndex := g.rand.Intn(len(children))
+	if children[addIndex] == nil {
+		node.SetParent(parent)
+		children[addIndex] = node
+	} else {
+		g.AddRandom(children[addIndex], node)
+	}
+}
@@ -0,0 +1,221 @@
+package apt
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	gotoken "go/token"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hultan/evolvingImage/noise"
+)
+
+// goTemplates are the Go expressions of the operators, see expand. They are
+// written exactly as in the Evaluate methods, so that the generated code
+// computes exactly the same values as the interpreter. Helper functions are
+// written as %s, and are prefixed to avoid name collisions.
+var goTemplates = map[string]string{
+	"Swirl":        "math.Sin(6*math.Cos($0) - $1*$0)",
+	"FBM":          "2*3.627*%sFbm2($0, $1, 5*$2, 0.5, 2, 3) + .492 - 1",
+	"Turbulence":   "2*6.96*%sTurbulence($0, $1, 5*$2, 0.5, 2, 3) - 1",
+	"Lerp":         "$0 + $2*($1-$0)",
+	"+":            "$0 + $1",
+	"-":            "$0 - $1",
+	"*":            "$0 * $1",
+	"/":            "$0 / $1",
+	"Atan2":        "math.Atan2(y, x)",
+	"SimplexNoise": "80*%sSnoise2($0, $1) - 2.0",
+	"Clip":         "%sClip($0, $1)",
+	"Square":       "$0 * $0",
+	"Log2":         "math.Log2($0)",
+	"Negate":       "-$0",
+	"Ceil":         "math.Ceil($0)",
+	"Floor":        "math.Floor($0)",
+	"Abs":          "math.Abs($0)",
+	"Wrap":         "%sWrap($0)",
+	"Sin":          "math.Sin($0)",
+	"Cos":          "math.Cos($0)",
+	"Atan":         "math.Atan($0)",
+}
+
+// goHelpers are the helper functions of the operators, written as in
+// their Evaluate methods.
+var goHelpers = map[string]string{
+	"Clip": `func %sClip(value, maxVal float64) float64 {
+	maxVal = math.Abs(maxVal)
+	if value > maxVal {
+		return maxVal
+	} else if value < -maxVal {
+		return -maxVal
+	}
+	return value
+}
+`,
+	"Wrap": `func %sWrap(f float64) float64 {
+	temp := (f - -1.0) / (2.0)
+	return -1.0 + 2.0*(temp-math.Floor(temp))
+}
+`,
+}
+
+// goNoiseFunctions are the declarations in the noise package needed by the noise operators.
+var goNoiseFunctions = map[string][]string{
+	"SimplexNoise": {"Snoise2", "grad2", "fastFloor", "toInt", "perm"},
+	"FBM":          {"Fbm2", "Snoise2", "grad2", "fastFloor", "toInt", "perm"},
+	"Turbulence":   {"Turbulence", "Snoise2", "grad2", "fastFloor", "toInt", "perm"},
+}
+
+// GenerateGo generates a gofmt-clean Go source file in package pkg, with a
+// standalone function
+//
+//	func funcName(x, y float64) (r, g, b float64)
+//
+// that computes the channels r, g and b of a picture at (x, y), exactly as
+// the interpreter does. The helper functions it needs (like the noise
+// functions) are included, prefixed by funcName so that several generated
+// files can be put in the same package. Registered operators need
+// Go code (see OperatorCode).
+func GenerateGo(r, g, b Node, pkg, funcName string) ([]byte, error) {
+	if !gotoken.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name %q", pkg)
+	}
+	if !gotoken.IsIdentifier(funcName) {
+		return nil, fmt.Errorf("invalid function name %q", funcName)
+	}
+	p, err := lower(r, g, b)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.ToLower(funcName[:1]) + funcName[1:]
+
+	var body bytes.Buffer
+	operands := make([]string, len(p.instrs))
+	for i, in := range p.instrs {
+		if in.op == "x" || in.op == "y" {
+			operands[i] = in.op
+			continue
+		}
+		operands[i] = fmt.Sprintf("v%d", i)
+		if in.op == constantName {
+			// Constants are variables, since the compiler evaluates
+			// arithmetic on constant literals with more precision
+			fmt.Fprintf(&body, "	%s := %s\n", operands[i], goLiteral(in.value))
+			continue
+		}
+
+		args := make([]string, len(in.args))
+		for j, arg := range in.args {
+			args[j] = operands[arg]
+		}
+		template, ok := goTemplates[in.op]
+		if !ok {
+			template, ok = registeredCode(in.op, func(c OperatorCode) string { return c.Go })
+		}
+		if !ok {
+			return nil, fmt.Errorf("operator %s can't be converted to Go", in.op)
+		}
+		template = strings.ReplaceAll(template, "%s", prefix)
+		fmt.Fprintf(&body, "\t%s := %s\n", operands[i], expand(template, args))
+	}
+	fmt.Fprintf(&body, "\treturn %s, %s, %s\n", operands[p.outputs[0]], operands[p.outputs[1]], operands[p.outputs[2]])
+
+	var helpers bytes.Buffer
+	for _, op := range []string{"Clip", "Wrap"} {
+		if p.uses(op) {
+			fmt.Fprintf(&helpers, "\n"+goHelpers[op], prefix)
+		}
+	}
+	var needed []string
+	for _, op := range []string{"SimplexNoise", "FBM", "Turbulence"} {
+		if p.uses(op) {
+			needed = append(needed, goNoiseFunctions[op]...)
+		}
+	}
+	if len(needed) > 0 {
+		if err = writeNoiseFunctions(&helpers, needed, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated from an evolved picture. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if strings.Contains(body.String(), "math.") || strings.Contains(helpers.String(), "math.") {
+		fmt.Fprintf(&src, "import \"math\"\n\n")
+	}
+	fmt.Fprintf(&src, "// %s returns the color components, nominally in [-1, 1], of the picture at (x, y).\n", funcName)
+	fmt.Fprintf(&src, "func %s(x, y float64) (r, g, b float64) {\n%s}\n", funcName, body.String())
+	src.Write(helpers.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// goLiteral returns a Go expression for a float64, that is exactly the value.
+func goLiteral(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "math.NaN()"
+	case math.IsInf(value, 1):
+		return "math.Inf(1)"
+	case math.IsInf(value, -1):
+		return "math.Inf(-1)"
+	case value == 0 && math.Signbit(value):
+		// Constant expressions can't be negative zero
+		return "math.Copysign(0, -1)"
+	}
+
+	s := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// writeNoiseFunctions copies the named declarations from the noise
+// package, with all the names prefixed.
+func writeNoiseFunctions(w *bytes.Buffer, names []string, prefix string) error {
+	fset := gotoken.NewFileSet()
+	file, err := parser.ParseFile(fset, "noise.go", noise.Source, 0)
+	if err != nil {
+		return err
+	}
+
+	rename := func(name string) string {
+		return prefix + strings.ToUpper(name[:1]) + name[1:]
+	}
+	isNeeded := func(name string) bool {
+		return slices.Contains(names, name)
+	}
+
+	for _, decl := range file.Decls {
+		var name string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name = d.Name.Name
+		case *ast.GenDecl:
+			if spec, ok := d.Specs[0].(*ast.ValueSpec); ok && d.Tok == gotoken.VAR {
+				name = spec.Names[0].Name
+			}
+		}
+		if !isNeeded(name) {
+			continue
+		}
+
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && isNeeded(ident.Name) {
+				ident.Name = rename(ident.Name)
+			}
+			return true
+		})
+		w.WriteString("\n")
+		if err = printer.Fprint(w, fset, decl); err != nil {
+			return err
+		}
+		w.WriteString("\n")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package apt
+
+import "slices"
+
+// EvaluateGrid evaluates node for every combination of the coordinates in
+// xs and ys, and stores the values row by row in out, which must have a
+// length of at least len(xs)*len(ys). The result is identical to calling
+// node.Evaluate(xs[i], ys[j]) for every pixel, but subtrees that only depend
+// on y are evaluated once per row, subtrees that only depend on x are
+// evaluated once per column, and constant subtrees are evaluated once.
+func EvaluateGrid(node Node, xs, ys []float64, out []float64) {
+	g := &grid{}
+	root := g.build(node, nil, Dependencies(node))
+
+	for _, c := range g.columns {
+		c.values = make([]float64, len(xs))
+		for i, x := range xs {
+			c.values[i] = c.source.Evaluate(x, 0)
+		}
+	}
+
+	index := 0
+	for _, y := range ys {
+		for _, r := range g.rows {
+			r.value = r.source.Evaluate(0, y)
+		}
+		for i, x := range xs {
+			g.column = i
+			out[index] = root.Evaluate(x, y)
+			index++
+		}
+	}
+}
+
+// EvaluateGridNaive does the same as EvaluateGrid, but by calling
+// node.Evaluate for every pixel. It is used to verify EvaluateGrid.
+func EvaluateGridNaive(node Node, xs, ys []float64, out []float64) {
+	index := 0
+	for _, y := range ys {
+		for _, x := range xs {
+			out[index] = node.Evaluate(x, y)
+			index++
+		}
+	}
+}
+
+// grid is the render plan of EvaluateGrid, a copy of the part of the tree
+// that depends on both x and y, where the largest subtrees that don't are
+// replaced by cached values.
+type grid struct {
+	rows    []*rowCache
+	columns []*columnCache
+	column  int
+}
+
+func (g *grid) build(node, parent Node, deps map[Node]Dependency) Node {
+	var result Node
+	switch dep := deps[node]; {
+	case len(node.GetChildren()) == 0:
+		result = copyNode(node, parent)
+	case dep == DependsOnNothing:
+		result = newConstant(node.Evaluate(0, 0))
+	case dep == DependsOnY:
+		r := &rowCache{source: node}
+		g.rows = append(g.rows, r)
+		result = r
+	case dep == DependsOnX:
+		c := &columnCache{source: node, column: &g.column}
+		g.columns = append(g.columns, c)
+		result = c
+	default:
+		result = copyNode(node, parent)
+		dead := deadChildren(node)
+		for i, child := range node.GetChildren() {
+			if slices.Contains(dead, i) {
+				result.GetChildren()[i] = newConstant(0)
+			} else {
+				result.GetChildren()[i] = g.build(child, result, deps)
+			}
+		}
+	}
+	result.SetParent(parent)
+	return result
+}
+
+// rowCache holds the value of a subtree that only depends on y, for the current row.
+type rowCache struct {
+	BaseNode
+	source Node
+	value  float64
+}
+
+func (c *rowCache) Evaluate(_, _ float64) float64 {
+	return c.value
+}
+
+func (c *rowCache) String() string {
+	return c.source.String()
+}
+
+// columnCache holds the values of a subtree that only depends on x, for every column.
+type columnCache struct {
+	BaseNode
+	source Node
+	values []float64
+	column *int
+}
+
+func (c *columnCache) Evaluate(_, _ float64) float64 {
+	return c.values[*c.column]
+}
+
+func (c *columnCache) String() string {
+	return c.source.String()
+}
@@ -0,0 +1,77 @@
+package apt
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// gridCoordinates returns the pixel coordinates of a size pixels wide grid,
+// like picture.Coordinates.
+func gridCoordinates(size int) []float64 {
+	coordinates := make([]float64, size)
+	for i := range coordinates {
+		coordinates[i] = float64(i)/float64(size)*2 - 1
+	}
+	return coordinates
+}
+
+// sameValue compares two values bit by bit, treating all NaNs as equal.
+func sameValue(a, b float64) bool {
+	if math.IsNaN(a) && math.IsNaN(b) {
+		return true
+	}
+	return math.Float64bits(a) == math.Float64bits(b)
+}
+
+// compareRenderers renders node with EvaluateGrid and EvaluateGridNaive,
+// and reports the first pixel where they differ.
+func compareRenderers(t *testing.T, name string, node Node) {
+	t.Helper()
+	xs, ys := gridCoordinates(64), gridCoordinates(48)
+	naive := make([]float64, len(xs)*len(ys))
+	cached := make([]float64, len(xs)*len(ys))
+	EvaluateGridNaive(node, xs, ys, naive)
+	EvaluateGrid(node, xs, ys, cached)
+	for i := range naive {
+		if !sameValue(naive[i], cached[i]) {
+			t.Errorf("%s : pixel (%d, %d) is %v with the cache, want %v",
+				name, i%len(xs), i/len(xs), cached[i], naive[i])
+			return
+		}
+	}
+}
+
+func TestEvaluateGridRandomTrees(t *testing.T) {
+	for seed := int64(1); seed <= 50; seed++ {
+		g := NewGenerator(seed)
+		for i, node := range g.RampedHalfAndHalf(6, 2, 7) {
+			compareRenderers(t, fmt.Sprintf("seed %d, tree %d", seed, i), node)
+		}
+	}
+}
+
+func TestEvaluateGridGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "testdata", "golden", "*.apt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files found")
+	}
+	for _, fileName := range files {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, node, err := ParseFile(string(data))
+		if err != nil {
+			t.Fatalf("%s : %v", fileName, err)
+		}
+		for i, channel := range node.GetChildren() {
+			compareRenderers(t, filepath.Base(fileName)+" channel "+"RGB"[i:i+1], channel)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package apt
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"slices"
+)
+
+// Hash returns a canonical structural hash of the tree. Constants are rounded
+// to a multiple of tolerance before they are hashed, so that trees whose
+// constants only differ slightly get the same hash (a tolerance of zero uses
+// the exact values). The children of commutative operators are hashed in a
+// canonical order, so ( + x y ) and ( + y x ) get the same hash.
+func Hash(node Node, tolerance float64) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+
+	switch n := node.(type) {
+	case *OperatorPicture:
+		h.Write([]byte("Picture"))
+	case *OperatorConstant:
+		h.Write([]byte(constantName))
+		value := n.Value
+		if tolerance > 0 {
+			value = math.Round(value / tolerance)
+		}
+		if value == 0 {
+			value = 0 // -0 and 0 are the same constant
+		}
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(value))
+		h.Write(buf[:])
+	default:
+		h.Write([]byte(specOf(node).Name))
+	}
+
+	childHashes := make([]uint64, len(node.GetChildren()))
+	for i, child := range node.GetChildren() {
+		childHashes[i] = Hash(child, tolerance)
+	}
+	if isCommutative(node) {
+		slices.Sort(childHashes)
+	}
+
+	for _, childHash := range childHashes {
+		binary.LittleEndian.PutUint64(buf[:], childHash)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// isCommutative returns true for operators where the order of the children doesn't matter.
+func isCommutative(node Node) bool {
+	switch node.(type) {
+	case *OperatorPlus, *OperatorMult:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package apt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatVersion is the version of the .apt format written by Header.String.
+const FormatVersion = 1
+
+// headerMagic starts the first line of a header, followed by the version.
+const headerMagic = "apt"
+
+// Header is the optional metadata block of an .apt file. It consists of
+// comment lines, and is written after the tree :
+//
+//	( Picture ...
+//	)
+//	; apt 1
+//	; colors = rgb
+//	; created = 2024-05-01T12:00:00Z
+//
+// Readers from before comments were supported stop reading at the end of
+// the tree, so they never see it, while a header at the top of the file
+// makes them fail. A header at the top is still read, but only if there
+// is none after the tree. Files without a header, written before the
+// format was versioned, have version 0. Unknown keys must be preserved or
+// ignored by readers.
+type Header struct {
+	Version int
+	Values  map[string]string
+}
+
+// NewHeader returns an empty header of the current version.
+func NewHeader() Header {
+	return Header{Version: FormatVersion, Values: make(map[string]string)}
+}
+
+// Get returns the value of key, or an empty string if it is not set.
+func (h Header) Get(key string) string {
+	return h.Values[key]
+}
+
+// Set sets the value of key. Keys consist of letters, digits, '-' and '_',
+// and line breaks in the value are replaced by spaces.
+func (h *Header) Set(key, value string) {
+	if !isHeaderKey(key) {
+		panic(fmt.Sprintf("invalid header key %q", key))
+	}
+	if h.Values == nil {
+		h.Values = make(map[string]string)
+	}
+	h.Values[key] = strings.Join(strings.Fields(value), " ")
+}
+
+// String formats the header, with the keys in alphabetical order. A header
+// of version 0 is formatted as an empty string.
+func (h Header) String() string {
+	if h.Version == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(h.Values))
+	for key := range h.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; %s %d\n", headerMagic, h.Version)
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "; %s = %s\n", key, h.Values[key])
+	}
+	return sb.String()
+}
+
+// ParseFile parses the header (if any) and the tree of an .apt file.
+// Errors are returned as a *ParseError.
+func ParseFile(input string) (Header, Node, error) {
+	header, err := parseHeader(input, trailerStart(input))
+	if err == nil && header.Version == 0 {
+		header, err = parseHeader(input, 0)
+	}
+	if err != nil {
+		return Header{}, nil, err
+	}
+	node, err := parseTree(input)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	return header, node, nil
+}
+
+// trailerStart returns where the comments and blank lines at the end of
+// input start, or len(input) if it doesn't end with a comment.
+func trailerStart(input string) int {
+	start := len(input)
+	for start > 0 {
+		begin := strings.LastIndexByte(input[:start-1], '\n') + 1
+		line := strings.TrimSpace(input[begin:start])
+		if line != "" && line[0] != ';' {
+			break
+		}
+		start = begin
+	}
+	return start
+}
+
+// parseHeader parses the comment lines of input from pos on. A header
+// starts with a "; apt <version>" line, and continues with "; key = value"
+// lines. Other comment lines are ignored.
+func parseHeader(input string, pos int) (Header, error) {
+	header := Header{}
+	for pos < len(input) {
+		end := strings.IndexByte(input[pos:], '\n')
+		if end < 0 {
+			end = len(input)
+		} else {
+			end += pos
+		}
+		line := strings.TrimSpace(input[pos:end])
+
+		if line != "" && line[0] != ';' {
+			break
+		}
+		if line != "" {
+			if err := header.parseLine(strings.TrimSpace(line[1:])); err != nil {
+				return Header{}, newParseError(input, pos, "%s", err)
+			}
+		}
+		pos = end + 1
+	}
+	return header, nil
+}
+
+func (h *Header) parseLine(line string) error {
+	if h.Version == 0 {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != headerMagic {
+			// Not a header, just a comment
+			return nil
+		}
+		version, err := strconv.Atoi(fields[1])
+		if err != nil || version < 1 {
+			return fmt.Errorf("invalid version %q", fields[1])
+		}
+		if version > FormatVersion {
+			return fmt.Errorf("unsupported version %d, the newest supported version is %d", version, FormatVersion)
+		}
+		*h = NewHeader()
+		h.Version = version
+		return nil
+	}
+
+	key, value, ok := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !ok || !isHeaderKey(key) {
+		// Not a key/value pair, just a comment
+		return nil
+	}
+	h.Values[key] = strings.TrimSpace(value)
+	return nil
+}
+
+func isHeaderKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package apt
+
+import "testing"
+
+func TestParseFileHeader(t *testing.T) {
+	tests := []struct {
+		name, input, colors string
+	}{
+		{"after the tree", "( Picture x y x )\n; apt 1\n; colors = hsv\n", "hsv"},
+		{"at the top", "; apt 1\n; colors = gray\n( Picture x y x )\n", "gray"},
+		{"both", "; apt 1\n; colors = gray\n( Picture x y x )\n\n; apt 1\n; colors = hsv\n", "hsv"},
+		{"none", "; a comment\n( Picture x y x )\n; another one\n", ""},
+	}
+	for _, test := range tests {
+		header, node, err := ParseFile(test.input)
+		if err != nil {
+			t.Errorf("%s : %v", test.name, err)
+			continue
+		}
+		if _, ok := node.(*OperatorPicture); !ok {
+			t.Errorf("%s : got %s, want a Picture", test.name, node)
+		}
+		if got := header.Get("colors"); got != test.colors {
+			t.Errorf("%s : colors = %q, want %q", test.name, got, test.colors)
+		}
+	}
+}
+
+func TestHeaderAfterFormat(t *testing.T) {
+	header := NewHeader()
+	header.Set("colors", "hsv")
+	node := BeginLexing("( Picture ( + x 0.5 ) y x )")
+
+	got, again, err := ParseFile(Format(node, DefaultFormatOptions) + header.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != FormatVersion || got.Get("colors") != "hsv" {
+		t.Errorf("header = %+v, want version %d and colors hsv", got, FormatVersion)
+	}
+	if !Equal(node, again) {
+		t.Errorf("tree = %s, want %s", again, node)
+	}
+}
@@ -0,0 +1,73 @@
+package apt
+
+// Grow builds a random tree using the grow method. Nodes above minDepth
+// are always operators, nodes at maxDepth are always leaves, and in
+// between operators and leaves are mixed, which gives trees of varying
+// shape. The root of the tree is at depth 1.
+func Grow(minDepth, maxDepth int) Node {
+	return defaultGenerator.Grow(minDepth, maxDepth)
+}
+
+// Grow works like the package level Grow, using the generator.
+func (g *Generator) Grow(minDepth, maxDepth int) Node {
+	return g.grow(nil, 1, minDepth, maxDepth, g.totalWeight(false), g.totalWeight(true))
+}
+
+// Full builds a random tree using the full method, where every
+// branch reaches exactly depth levels.
+func Full(depth int) Node {
+	return defaultGenerator.Full(depth)
+}
+
+// Full works like the package level Full, using the generator.
+func (g *Generator) Full(depth int) Node {
+	return g.grow(nil, 1, depth, depth, 0, 0)
+}
+
+// RampedHalfAndHalf builds n random trees with their depths ramped evenly
+// between minDepth and maxDepth. For each depth, half of the trees are
+// built using Grow and the other half using Full.
+func RampedHalfAndHalf(n, minDepth, maxDepth int) []Node {
+	return defaultGenerator.RampedHalfAndHalf(n, minDepth, maxDepth)
+}
+
+// RampedHalfAndHalf works like the package level RampedHalfAndHalf, using the generator.
+func (g *Generator) RampedHalfAndHalf(n, minDepth, maxDepth int) []Node {
+	if maxDepth < minDepth {
+		maxDepth = minDepth
+	}
+	levels := maxDepth - minDepth + 1
+
+	trees := make([]Node, n)
+	for i := range trees {
+		depth := minDepth + i%levels
+		if (i/levels)%2 == 0 {
+			trees[i] = g.Grow(minDepth, depth)
+		} else {
+			trees[i] = g.Full(depth)
+		}
+	}
+	return trees
+}
+
+// grow builds the tree for Grow and Full, operatorWeight and leafWeight are
+// the total weights used to choose between operators and leaves.
+func (g *Generator) grow(parent Node, depth, minDepth, maxDepth int, operatorWeight, leafWeight float64) Node {
+	var node Node
+	switch {
+	case depth >= maxDepth:
+		node = g.RandomLeafNode()
+	case depth < minDepth:
+		node = g.RandomNode()
+	case g.rand.Float64()*(operatorWeight+leafWeight) < leafWeight:
+		node = g.RandomLeafNode()
+	default:
+		node = g.RandomNode()
+	}
+
+	node.SetParent(parent)
+	for i := range node.GetChildren() {
+		node.GetChildren()[i] = g.grow(node, depth+1, minDepth, maxDepth, operatorWeight, leafWeight)
+	}
+	return node
+}
@@ -0,0 +1,63 @@
+package apt
+
+import "slices"
+
+// Dependency tells which of the coordinates x and y a subtree depends on.
+type Dependency int
+
+const (
+	DependsOnNothing Dependency = 0
+	DependsOnX       Dependency = 1
+	DependsOnY       Dependency = 2
+	DependsOnXY                 = DependsOnX | DependsOnY
+)
+
+func (d Dependency) String() string {
+	switch d {
+	case DependsOnNothing:
+		return "none"
+	case DependsOnX:
+		return "x"
+	case DependsOnY:
+		return "y"
+	default:
+		return "xy"
+	}
+}
+
+// Dependencies tags every node in the tree with the coordinates that its
+// value depends on. Children that are never evaluated (see Simplify) are
+// not taken into account, and operators that are not built in are assumed
+// to depend on both x and y, since their Eval function is given both.
+func Dependencies(node Node) map[Node]Dependency {
+	deps := make(map[Node]Dependency)
+	tagDependencies(node, deps)
+	return deps
+}
+
+func tagDependencies(node Node, deps map[Node]Dependency) Dependency {
+	var dep Dependency
+	switch node.(type) {
+	case *OperatorX:
+		dep = DependsOnX
+	case *OperatorY:
+		dep = DependsOnY
+	case *OperatorAtan2:
+		dep = DependsOnXY
+	default:
+		if !isBuiltIn(node) {
+			dep = DependsOnXY
+		}
+	}
+
+	dead := deadChildren(node)
+	for i, child := range node.GetChildren() {
+		childDep := tagDependencies(child, deps)
+		if !slices.Contains(dead, i) {
+			dep |= childDep
+		}
+	}
+
+	deps[node] = dep
+	return dep
+}
@@ -0,0 +1,155 @@
+package apt
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hultan/evolvingImage/noise"
+)
+
+// jsRuntime are the math and noise functions used by the generated
+// JavaScript. JavaScript's own Math functions are not exact, and differ
+// between browsers, so they are ported from Go instead.
+//
+//go:embed runtime.js
+var jsRuntime string
+
+// jsTemplates are the expressions of the operators in JavaScript, see expand.
+// They follow the Evaluate methods operation by operation, so that the
+// results are exactly those of the interpreter.
+var jsTemplates = map[string]string{
+	"Swirl":        "sin(6*cos($0) - $1*$0)",
+	"FBM":          "2*3.627*fbm2($0, $1, 5*$2, 0.5, 2, 3) + .492 - 1",
+	"Turbulence":   "2*6.96*turbulence($0, $1, 5*$2, 0.5, 2, 3) - 1",
+	"Lerp":         "$0 + $2*($1-$0)",
+	"+":            "$0 + $1",
+	"-":            "$0 - $1",
+	"*":            "$0 * $1",
+	"/":            "$0 / $1",
+	"Atan2":        "atan2(y, x)",
+	"SimplexNoise": "80*snoise2($0, $1) - 2.0",
+	"Clip":         "clip($0, $1)",
+	"Square":       "$0 * $0",
+	"Log2":         "log2($0)",
+	"Negate":       "-$0",
+	"Ceil":         "Math.ceil($0)",
+	"Floor":        "Math.floor($0)",
+	"Abs":          "Math.abs($0)",
+	"Wrap":         "wrap($0)",
+	"Sin":          "sin($0)",
+	"Cos":          "cos($0)",
+	"Atan":         "atan($0)",
+}
+
+// GenerateJS generates a self-contained JavaScript module that computes
+// the picture with the channels r, g and b, with exactly the same pixels
+// as the Go renderer. The module exports
+//
+//	picture(x, y)           the channels [r, g, b] at (x, y)
+//	pixels(width, height)   the RGBA pixels, like picture.Picture.Pixels
+//	render(canvas)          draws the picture on a <canvas>
+//
+// Registered operators need JavaScript code (see OperatorCode).
+func GenerateJS(r, g, b Node) (string, error) {
+	p, err := lower(r, g, b)
+	if err != nil {
+		return "", err
+	}
+
+	var body strings.Builder
+	operands := make([]string, len(p.instrs))
+	for i, in := range p.instrs {
+		switch in.op {
+		case "x", "y":
+			operands[i] = in.op
+			continue
+		case constantName:
+			operands[i] = jsLiteral(in.value)
+			continue
+		}
+
+		template, ok := jsTemplates[in.op]
+		if !ok {
+			template, ok = registeredCode(in.op, func(c OperatorCode) string { return c.JS })
+		}
+		if !ok {
+			return "", fmt.Errorf("operator %s can't be converted to JavaScript", in.op)
+		}
+		args := make([]string, len(in.args))
+		for j, arg := range in.args {
+			args[j] = operands[arg]
+		}
+		operands[i] = fmt.Sprintf("v%d", i)
+		fmt.Fprintf(&body, "\tconst %s = %s;\n", operands[i], expand(template, args))
+	}
+
+	var src strings.Builder
+	src.WriteString("// Generated from an evolved picture.\n\n")
+	src.WriteString(jsRuntime)
+	src.WriteString(jsPermutationTable())
+	src.WriteString("\nexport function picture(x, y) {\n")
+	src.WriteString(body.String())
+	fmt.Fprintf(&src, "\treturn [%s, %s, %s];\n}\n", operands[p.outputs[0]], operands[p.outputs[1]], operands[p.outputs[2]])
+	src.WriteString(jsMain)
+	return src.String(), nil
+}
+
+// jsLiteral returns a JavaScript literal for a constant. JavaScript numbers
+// are float64, and parse like Go floats.
+func jsLiteral(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// jsPermutationTable returns the permutation table of the noise functions.
+func jsPermutationTable() string {
+	perm := noise.Permutation()
+	values := make([]string, len(perm))
+	for i, v := range perm {
+		values[i] = strconv.Itoa(int(v))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\nconst perm = new Uint8Array([")
+	for i := 0; i < len(values); i += 16 {
+		sb.WriteString("\n\t" + strings.Join(values[i:i+16], ", ") + ",")
+	}
+	sb.WriteString("\n]);\n")
+	return sb.String()
+}
+
+// jsMain maps the pixels to coordinates like picture.Picture.Pixels does.
+const jsMain = `
+export function pixels(width, height) {
+	const pixels = new Uint8ClampedArray(width * height * 4);
+	for (let yi = 0; yi < height; yi++) {
+		const y = yi / height * 2 - 1;
+		for (let xi = 0; xi < width; xi++) {
+			const x = xi / width * 2 - 1;
+			const [r, g, b] = picture(x, y);
+			const i = (yi * width + xi) * 4;
+			pixels[i] = toByte(r);
+			pixels[i + 1] = toByte(g);
+			pixels[i + 2] = toByte(b);
+			pixels[i + 3] = 255;
+		}
+	}
+	return pixels;
+}
+
+export function render(canvas) {
+	const { width, height } = canvas;
+	const image = new ImageData(pixels(width, height), width, height);
+	canvas.getContext("2d").putImageData(image, 0, 0);
+}
+`
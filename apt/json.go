@@ -0,0 +1,84 @@
+package apt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// The JSON form of a tree is :
+//
+//	operator : {"op": "Lerp", "args": [child1, child2, child3]}
+//	leaf     : {"op": "x"}
+//	constant : 0.25
+//
+// where "op" is the name of the operator as in the .apt format (see
+// Operators) and "args" holds exactly as many children as the arity of
+// the operator. A Picture node is written as {"op": "Picture", "args": [r, g, b]}.
+// Constants must be finite, since JSON has no NaN or infinities.
+
+// jsonNode is the JSON form of an operator.
+type jsonNode struct {
+	Op   string            `json:"op"`
+	Args []json.RawMessage `json:"args,omitempty"`
+}
+
+// MarshalJSON returns the JSON form of a tree.
+func MarshalJSON(node Node) ([]byte, error) {
+	if c, ok := node.(*OperatorConstant); ok {
+		if math.IsNaN(c.Value) || math.IsInf(c.Value, 0) {
+			return nil, fmt.Errorf("constant %v can't be represented in JSON", c.Value)
+		}
+		return json.Marshal(c.Value)
+	}
+
+	n := jsonNode{Op: NodeName(node)}
+	for _, child := range node.GetChildren() {
+		arg, err := MarshalJSON(child)
+		if err != nil {
+			return nil, err
+		}
+		n.Args = append(n.Args, arg)
+	}
+	return json.Marshal(n)
+}
+
+// UnmarshalJSON creates a tree from its JSON form.
+func UnmarshalJSON(data []byte) (Node, error) {
+	return unmarshalJSON(data, nil)
+}
+
+func unmarshalJSON(data []byte, parent Node) (Node, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		var value float64
+		if err := json.Unmarshal(data, &value); err != nil || string(data) == "null" {
+			return nil, fmt.Errorf("expected an operator or a constant, got %s", data)
+		}
+		c := NewConstant()
+		c.Value = value
+		c.SetParent(parent)
+		return c, nil
+	}
+
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	node, err := stringToNode(n.Op)
+	if err != nil {
+		return nil, err
+	}
+	children := node.GetChildren()
+	if len(n.Args) != len(children) {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", n.Op, len(children), len(n.Args))
+	}
+	node.SetParent(parent)
+	for i, arg := range n.Args {
+		if children[i], err = unmarshalJSON(arg, node); err != nil {
+			return nil, fmt.Errorf("%s argument %d : %w", n.Op, i+1, err)
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,120 @@
+package apt
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// program is a set of trees lowered to a list of instructions in static
+// single assignment form, which is what the code generators (GenerateGo...)
+// work from. Identical subtrees are only computed once, and children that
+// are never evaluated (see Simplify) are left out.
+type program struct {
+	instrs  []instr
+	outputs []int // the instruction of each root
+}
+
+// instr computes the value of an operator from the values of earlier
+// instructions. The arguments are the live children of the operator.
+type instr struct {
+	op    string // operator name, as in the .apt format
+	args  []int
+	value float64 // for constants
+}
+
+// lower lowers the trees to a program. Operators added with Register are
+// lowered too, but can only be converted to the languages that they have
+// code for (see OperatorCode).
+func lower(roots ...Node) (*program, error) {
+	l := lowering{seen: make(map[string]int)}
+	for _, root := range roots {
+		i, err := l.lower(root)
+		if err != nil {
+			return nil, err
+		}
+		l.p.outputs = append(l.p.outputs, i)
+	}
+	return &l.p, nil
+}
+
+type lowering struct {
+	p    program
+	seen map[string]int // instruction key -> index
+}
+
+func (l *lowering) lower(node Node) (int, error) {
+	if _, ok := node.(*OperatorPicture); ok {
+		return 0, fmt.Errorf("can't lower a Picture node, lower its channels instead")
+	}
+	in := instr{op: binaryName(node)}
+	if c, ok := node.(*OperatorConstant); ok {
+		in.value = c.Value
+	}
+	dead := deadChildren(node)
+	for i, child := range node.GetChildren() {
+		if slices.Contains(dead, i) {
+			continue
+		}
+		arg, err := l.lower(child)
+		if err != nil {
+			return 0, err
+		}
+		in.args = append(in.args, arg)
+	}
+
+	key := fmt.Sprint(in.op, in.args, math.Float64bits(in.value))
+	if i, ok := l.seen[key]; ok {
+		return i, nil
+	}
+	l.p.instrs = append(l.p.instrs, in)
+	l.seen[key] = len(l.p.instrs) - 1
+	return len(l.p.instrs) - 1, nil
+}
+
+// uses returns true if any instruction has one of the operators.
+func (p *program) uses(ops ...string) bool {
+	for _, in := range p.instrs {
+		if slices.Contains(ops, in.op) {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredCode returns the template of a registered operator in a
+// language, or false if it has none.
+func registeredCode(op string, language func(OperatorCode) string) (string, bool) {
+	spec, ok := operatorsByName[op]
+	if !ok || spec.builtIn {
+		return "", false
+	}
+	template := language(spec.Code)
+	return template, template != ""
+}
+
+// expand replaces $0, $1... in an expression template with the operands,
+// and $x and $y with the coordinates x and y. Negative operands that
+// follow a minus are put in parentheses, to avoid "--".
+func expand(template string, operands []string) string {
+	var sb strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] == '$' && i+1 < len(template) && (template[i+1] == 'x' || template[i+1] == 'y') {
+			sb.WriteByte(template[i+1])
+			i++
+			continue
+		}
+		if template[i] == '$' && i+1 < len(template) && template[i+1] >= '0' && template[i+1] <= '9' {
+			operand := operands[template[i+1]-'0']
+			if i > 0 && template[i-1] == '-' && strings.HasPrefix(operand, "-") {
+				operand = "(" + operand + ")"
+			}
+			sb.WriteString(operand)
+			i++
+			continue
+		}
+		sb.WriteByte(template[i])
+	}
+	return sb.String()
+}
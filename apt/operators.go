@@ -49,7 +49,7 @@ func (op *OperatorSwirl) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorSwirl) String() string {
-	return "( Swirl " + op.Children[0].String() + " " + op.Children[1].String() + " " + op.Children[2].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorFBM struct {
@@ -71,7 +71,7 @@ func (op *OperatorFBM) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorFBM) String() string {
-	return "( FBM " + op.Children[0].String() + " " + op.Children[1].String() + " " + op.Children[2].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorTurbulence struct {
@@ -93,7 +93,7 @@ func (op *OperatorTurbulence) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorTurbulence) String() string {
-	return "( Turbulence " + op.Children[0].String() + " " + op.Children[1].String() + " " + op.Children[2].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorLerp struct {
@@ -117,7 +117,7 @@ func (op *OperatorLerp) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorLerp) String() string {
-	return "( Lerp " + op.Children[0].String() + " " + op.Children[1].String() + " " + op.Children[2].String() + " )"
+	return FormatOperator(op)
 }
 
 //
@@ -142,7 +142,7 @@ func (op *OperatorPlus) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorPlus) String() string {
-	return "( + " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorMinus struct {
@@ -163,7 +163,7 @@ func (op *OperatorMinus) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorMinus) String() string {
-	return "( - " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorMult struct {
@@ -184,7 +184,7 @@ func (op *OperatorMult) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorMult) String() string {
-	return "( * " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorDiv struct {
@@ -205,7 +205,7 @@ func (op *OperatorDiv) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorDiv) String() string {
-	return "( / " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorAtan2 struct {
@@ -226,7 +226,7 @@ func (op *OperatorAtan2) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorAtan2) String() string {
-	return "( Atan2 " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorNoise struct {
@@ -247,7 +247,7 @@ func (op *OperatorNoise) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorNoise) String() string {
-	return "( SimplexNoise " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorClip struct {
@@ -276,7 +276,7 @@ func (op *OperatorClip) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorClip) String() string {
-	return "( Clip " + op.Children[0].String() + " " + op.Children[1].String() + " )"
+	return FormatOperator(op)
 }
 
 //
@@ -302,7 +302,7 @@ func (op *OperatorSquare) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorSquare) String() string {
-	return "( Square " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorLog2 struct {
@@ -323,7 +323,7 @@ func (op *OperatorLog2) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorLog2) String() string {
-	return "( Log2 " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorNegate struct {
@@ -344,7 +344,7 @@ func (op *OperatorNegate) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorNegate) String() string {
-	return "( Negate " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorCeil struct {
@@ -365,7 +365,7 @@ func (op *OperatorCeil) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorCeil) String() string {
-	return "( Ceil " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorFloor struct {
@@ -386,7 +386,7 @@ func (op *OperatorFloor) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorFloor) String() string {
-	return "( Floor " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorAbs struct {
@@ -407,7 +407,7 @@ func (op *OperatorAbs) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorAbs) String() string {
-	return "( Abs " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorWrap struct {
@@ -430,7 +430,7 @@ func (op *OperatorWrap) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorWrap) String() string {
-	return "( Wrap " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorSin struct {
@@ -451,7 +451,7 @@ func (op *OperatorSin) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorSin) String() string {
-	return "( Sin " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorCos struct {
@@ -472,7 +472,7 @@ func (op *OperatorCos) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorCos) String() string {
-	return "( Cos " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 type OperatorAtan struct {
@@ -493,7 +493,7 @@ func (op *OperatorAtan) Evaluate(x, y float64) float64 {
 }
 
 func (op *OperatorAtan) String() string {
-	return "( Atan " + op.Children[0].String() + " )"
+	return FormatOperator(op)
 }
 
 //
@@ -518,7 +518,7 @@ func (op *OperatorX) Evaluate(x, _ float64) float64 {
 }
 
 func (op *OperatorX) String() string {
-	return "x"
+	return FormatOperator(op)
 }
 
 type OperatorY struct {
@@ -539,7 +539,7 @@ func (op *OperatorY) Evaluate(_, y float64) float64 {
 }
 
 func (op *OperatorY) String() string {
-	return "y"
+	return FormatOperator(op)
 }
 
 type OperatorConstant struct {
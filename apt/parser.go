@@ -23,6 +23,7 @@ const (
 type token struct {
 	typ   tokenType
 	value string
+	pos   int
 }
 
 type lexer struct {
@@ -35,101 +36,100 @@ type lexer struct {
 
 type stateFunc func(*lexer) stateFunc
 
-func stringToNode(s string) Node {
-	switch s {
-	case "Picture":
-		return NewPicture()
-	case "+":
-		return NewPlus()
-	case "-":
-		return NewMinus()
-	case "*":
-		return NewMult()
-	case "/":
-		return NewDiv()
-	case "Atan2":
-		return NewAtan2()
-	case "Atan":
-		return NewAtan()
-	case "Cos":
-		return NewCos()
-	case "Sin":
-		return NewSin()
-	case "SimplexNoise":
-		return NewNoise()
-	case "Square":
-		return NewSquare()
-	case "Log2":
-		return NewLog2()
-	case "Negate":
-		return NewNegate()
-	case "Ceil":
-		return NewCeil()
-	case "Floor":
-		return NewFloor()
-	case "Abs":
-		return NewAbs()
-	case "Clip":
-		return NewClip()
-	case "Wrap":
-		return NewWrap()
-	case "Lerp":
-		return NewLerp()
-	case "FBM":
-		return NewFBM()
-	case "Turbulence":
-		return NewTurbulence()
-	case "Swirl":
-		return NewSwirl()
-	case "x":
-		return NewX()
-	case "y":
-		return NewY()
-	default:
-		panic(fmt.Sprintf("Unknown token type: %s", s))
-	}
-}
-
-func parse(tokens chan token, parent Node) Node {
+// ParseError is returned when an .apt file can't be parsed.
+type ParseError struct {
+	Line   int // 1-based
+	Column int // 1-based, in runes
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d : %s", e.Line, e.Column, e.Msg)
+}
+
+// newParseError creates a ParseError at the byte offset pos of input.
+func newParseError(input string, pos int, format string, args ...any) *ParseError {
+	pos = min(pos, len(input))
+	line := strings.Count(input[:pos], "\n") + 1
+	column := utf8.RuneCountInString(input[strings.LastIndex(input[:pos], "\n")+1:pos]) + 1
+	return &ParseError{Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func stringToNode(s string) (Node, error) {
+	if s == "Picture" {
+		return NewPicture(), nil
+	}
+	spec, ok := operatorsByName[s]
+	if !ok || s == constantName {
+		return nil, fmt.Errorf("unknown operator %q", s)
+	}
+	return spec.newNode(), nil
+}
+
+func parse(input string, tokens chan token, parent Node) (Node, error) {
 	for {
 		tok, ok := <-tokens
 		if !ok {
-			panic("no more tokens")
+			return nil, newParseError(input, len(input), "unexpected end of file")
 		}
 		switch tok.typ {
 		case operator:
-			n := stringToNode(tok.value)
+			n, err := stringToNode(tok.value)
+			if err != nil {
+				return nil, newParseError(input, tok.pos, "%s", err)
+			}
 			n.SetParent(parent)
 			for i := range n.GetChildren() {
-				n.GetChildren()[i] = parse(tokens, n)
+				if n.GetChildren()[i], err = parse(input, tokens, n); err != nil {
+					return nil, err
+				}
 			}
-			return n
+			return n, nil
 		case constant:
 			n := NewConstant()
 			n.SetParent(parent)
 			num, err := strconv.ParseFloat(tok.value, 64)
 			if err != nil {
-				panic(fmt.Sprintf("Error while parsing constant : %s", err))
+				return nil, newParseError(input, tok.pos, "invalid constant %q", tok.value)
 			}
 			n.Value = num
-			return n
+			return n, nil
 		case openParen:
 			continue
 		case closeParen:
 			continue
 		}
 	}
-	return nil
 }
 
+// BeginLexing parses a tree in the .apt format, skipping any header and
+// comments. It panics if the input can't be parsed, see ParseFile for a
+// version that returns errors.
 func BeginLexing(input string) Node {
+	node, err := parseTree(input)
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+func parseTree(input string) (Node, error) {
 	l := &lexer{
 		input:  input,
 		tokens: make(chan token, 100), // Buffered so that the lexer can work independently of the parser
 	}
 
 	go l.run()
-	return parse(l.tokens, nil)
+	node, err := parse(input, l.tokens, nil)
+	for tok := range l.tokens {
+		if err == nil && tok.typ != closeParen {
+			err = newParseError(input, tok.pos, "unexpected %q after the end of the tree", tok.value)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
 }
 
 func (l *lexer) run() {
@@ -144,6 +144,8 @@ func determineToken(l *lexer) stateFunc {
 		switch r := l.next(); {
 		case isWhiteSpace(r):
 			l.ignore()
+		case r == ';':
+			return lexComment
 		case r == '(':
 			l.emit(openParen)
 		case r == ')':
@@ -158,6 +160,14 @@ func determineToken(l *lexer) stateFunc {
 	}
 }
 
+// lexComment skips a comment, from a semicolon to the end of the line.
+func lexComment(l *lexer) stateFunc {
+	for r := l.next(); r != '\n' && r != EOF; r = l.next() {
+	}
+	l.ignore()
+	return determineToken
+}
+
 func lexOp(l *lexer) stateFunc {
 	l.acceptRun("+-/*abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 	l.emit(operator)
@@ -199,6 +209,7 @@ func (l *lexer) emit(t tokenType) {
 	l.tokens <- token{
 		t,
 		l.input[l.start:l.pos],
+		l.start,
 	}
 	l.start = l.pos
 }
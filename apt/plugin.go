@@ -0,0 +1,107 @@
+package apt
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// Register adds a third-party operator to the registry, so that it takes
+// part in random generation, mutation, parsing and printing just like the
+// built-in operators. With Code, it can be converted to Go, shaders and
+// JavaScript as well. Register should be called from an init function,
+// before any trees are generated or parsed. For example :
+//
+//	func init() {
+//		err := apt.Register(apt.OperatorSpec{
+//			Name:    "Stripes",
+//			Arity:   1,
+//			Eval:    func(x, y float64, args []float64) float64 { return math.Sin(args[0] * 20 * x) },
+//			Weight:  1,
+//			Enabled: true,
+//			Code: apt.OperatorCode{
+//				Go:   "math.Sin($0 * 20 * $x)",
+//				GLSL: "sin($0 * 20.0 * $x)",
+//				WGSL: "sin($0 * 20.0 * $x)",
+//				JS:   "Math.sin($0 * 20 * $x)",
+//			},
+//		})
+//		if err != nil {
+//			panic(err)
+//		}
+//	}
+func Register(spec OperatorSpec) error {
+	if err := validateSpec(spec); err != nil {
+		return err
+	}
+	return register(spec)
+}
+
+func validateSpec(spec OperatorSpec) error {
+	if spec.Name == "" {
+		return errors.New("operator name is empty")
+	}
+	if spec.Name == "Picture" {
+		return errors.New("operator name Picture is reserved")
+	}
+	// The name must be lexed as a single operator token
+	for i, r := range spec.Name {
+		isLetter := r <= unicode.MaxASCII && unicode.IsLetter(r)
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return fmt.Errorf("operator name %q must start with a letter and only contain letters and digits", spec.Name)
+		}
+	}
+	if spec.Arity < 0 {
+		return fmt.Errorf("operator %s has a negative arity", spec.Name)
+	}
+	if spec.Weight < 0 {
+		return fmt.Errorf("operator %s has a negative weight", spec.Name)
+	}
+	for _, template := range []string{spec.Code.Go, spec.Code.GLSL, spec.Code.WGSL, spec.Code.JS} {
+		for i := 0; i < len(template)-1; i++ {
+			if template[i] == '$' && template[i+1] >= '0' && template[i+1] <= '9' && int(template[i+1]-'0') >= spec.Arity {
+				return fmt.Errorf("operator %s : the code %q uses $%c, but the operator has %d children",
+					spec.Name, template, template[i+1], spec.Arity)
+			}
+		}
+	}
+	if spec.New == nil && spec.Eval == nil {
+		return fmt.Errorf("operator %s needs either New or Eval", spec.Name)
+	}
+	if spec.New != nil {
+		node := spec.New()
+		if node == nil {
+			return fmt.Errorf("operator %s : New returned nil", spec.Name)
+		}
+		if len(node.GetChildren()) != spec.Arity {
+			return fmt.Errorf("operator %s : New returned a node with %d children, expected %d",
+				spec.Name, len(node.GetChildren()), spec.Arity)
+		}
+	}
+	return nil
+}
+
+// OperatorFunc is the node type used for registered operators
+// that are defined by an Eval function instead of a node type.
+type OperatorFunc struct {
+	BaseNode
+	spec *OperatorSpec
+}
+
+func (op *OperatorFunc) Evaluate(x, y float64) float64 {
+	args := make([]float64, len(op.Children))
+	for i, child := range op.Children {
+		args[i] = child.Evaluate(x, y)
+	}
+	return op.spec.Eval(x, y, args)
+}
+
+func (op *OperatorFunc) String() string {
+	return FormatOperator(op)
+}
+
+// Name returns the registered name of the operator.
+func (op *OperatorFunc) Name() string {
+	return op.spec.Name
+}
@@ -0,0 +1,112 @@
+package apt
+
+import (
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The test operators are disabled, so that they are not generated by the
+// other tests.
+func init() {
+	specs := []OperatorSpec{
+		{
+			Name:  "TestStripes",
+			Arity: 1,
+			Eval:  func(x, y float64, args []float64) float64 { return math.Sin(args[0] * 20 * x) },
+			Code: OperatorCode{
+				Go:   "math.Sin($0 * 20 * $x)",
+				GLSL: "sin($0 * 20.0 * $x)",
+				WGSL: "sin($0 * 20.0 * $x)",
+				JS:   "Math.sin($0 * 20 * $x)",
+			},
+		},
+		{
+			Name:  "TestNoCode",
+			Arity: 0,
+			Eval:  func(x, y float64, args []float64) float64 { return x * y },
+		},
+	}
+	for _, spec := range specs {
+		if err := Register(spec); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestRegisterCodeArity(t *testing.T) {
+	err := Register(OperatorSpec{
+		Name:  "TestBadCode",
+		Arity: 1,
+		Eval:  func(x, y float64, args []float64) float64 { return args[0] },
+		Code:  OperatorCode{Go: "$0 + $1"},
+	})
+	if err == nil {
+		t.Error("Register accepted code that uses a missing child")
+	}
+}
+
+func TestGenerateRegistered(t *testing.T) {
+	r := BeginLexing("( TestStripes ( + y 0.25 ) )")
+	g, b := NewX(), NewY()
+
+	src, err := GenerateGo(r, g, b, "pic", "Picture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "math.Sin(v") || !strings.Contains(string(src), "* 20 * x)") {
+		t.Errorf("GenerateGo doesn't use the code of TestStripes :\n%s", src)
+	}
+	for _, language := range []ShaderLanguage{GLSL, WGSL} {
+		src, err := GenerateShader(r, g, b, language)
+		if err != nil {
+			t.Fatalf("%s : %v", language, err)
+		}
+		if !strings.Contains(src, "* 20.0 * x)") {
+			t.Errorf("%s doesn't use the code of TestStripes :\n%s", language, src)
+		}
+	}
+	if mismatches, err := CompareFloat32(r, g, b, 32, 1e-3); err != nil || mismatches > 0 {
+		t.Errorf("CompareFloat32 = %d, %v, want no mismatches", mismatches, err)
+	}
+}
+
+func TestGenerateJSRegistered(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	r := BeginLexing("( TestStripes ( + y 0.25 ) )")
+	src, err := GenerateJS(r, NewX(), NewY())
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := filepath.Join(t.TempDir(), "picture.mjs")
+	if err = os.WriteFile(module, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := "const { picture } = await import(" + strconv.Quote(module) + "); console.log(picture(0.3, -0.6)[0]);"
+	out, err := exec.Command(node, "--input-type=module", "-e", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	got, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := r.Evaluate(0.3, -0.6); got != want {
+		t.Errorf("JavaScript gives %v, want %v", got, want)
+	}
+}
+
+func TestGenerateWithoutCode(t *testing.T) {
+	r := BeginLexing("( + TestNoCode x )")
+	_, err := GenerateGo(r, NewX(), NewY(), "pic", "Picture")
+	if err == nil || !strings.Contains(err.Error(), "TestNoCode") {
+		t.Errorf("GenerateGo = %v, want an error about TestNoCode", err)
+	}
+}
@@ -0,0 +1,415 @@
+package apt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// OperatorSpec describes an operator that can be used in a tree.
+type OperatorSpec struct {
+	// Name is used when parsing and printing trees, for example "Lerp".
+	Name string
+	// Arity is the number of children, leaves have an arity of 0.
+	Arity int
+	// New creates a new node for the operator, with Arity empty children.
+	// The Evaluate method of the node evaluates the operator, and the
+	// String method should use FormatOperator. New can be left nil when
+	// Eval is set.
+	New func() Node
+	// Eval evaluates the operator at the point x, y, given the values of
+	// its children. It is used to create OperatorFunc nodes for operators
+	// that don't have a New function.
+	Eval func(x, y float64, args []float64) float64
+	// Weight is the relative chance of the operator being picked by
+	// GetRandomNode (or GetRandomLeafNode for leaves).
+	Weight float64
+	// Enabled operators take part in random generation. Disabled
+	// operators can still be parsed.
+	Enabled bool
+	// Code is the operator in the languages that pictures are converted
+	// to, by GenerateGo, GenerateShader and GenerateJS. It is only used
+	// for registered operators, the built-in ones are known.
+	Code OperatorCode
+
+	builtIn bool
+}
+
+// OperatorCode holds expression templates of a registered operator, for
+// the code generators. In a template, $0, $1... are the values of the
+// children, and $x and $y are the coordinates. For example, an operator
+// that evaluates math.Sin(args[0] * 20 * x) is "math.Sin($0 * 20 * $x)" in
+// Go and "sin($0 * 20.0 * $x)" in GLSL. The templates can only use the
+// standard library of the language (the math package in Go). Pictures
+// with an operator that has no template for a language can't be converted
+// to it.
+type OperatorCode struct {
+	Go, GLSL, WGSL, JS string
+}
+
+// constantName is the registry name of OperatorConstant. Constants are
+// printed and parsed as numbers, so the name is only used for settings.
+const constantName = "Constant"
+
+var (
+	operators       []*OperatorSpec
+	operatorsByName = map[string]*OperatorSpec{}
+	operatorsByType = map[reflect.Type]*OperatorSpec{}
+)
+
+func init() {
+	builtIns := []OperatorSpec{
+		{Name: "+", New: func() Node { return NewPlus() }},
+		{Name: "-", New: func() Node { return NewMinus() }},
+		{Name: "*", New: func() Node { return NewMult() }},
+		{Name: "/", New: func() Node { return NewDiv() }},
+		{Name: "Atan2", New: func() Node { return NewAtan2() }},
+		{Name: "Atan", New: func() Node { return NewAtan() }},
+		{Name: "Cos", New: func() Node { return NewCos() }},
+		{Name: "Sin", New: func() Node { return NewSin() }},
+		{Name: "SimplexNoise", New: func() Node { return NewNoise() }},
+		{Name: "Square", New: func() Node { return NewSquare() }},
+		{Name: "Log2", New: func() Node { return NewLog2() }},
+		{Name: "Negate", New: func() Node { return NewNegate() }},
+		{Name: "Ceil", New: func() Node { return NewCeil() }},
+		{Name: "Floor", New: func() Node { return NewFloor() }},
+		{Name: "Abs", New: func() Node { return NewAbs() }},
+		{Name: "Clip", New: func() Node { return NewClip() }},
+		{Name: "Wrap", New: func() Node { return NewWrap() }},
+		{Name: "Lerp", New: func() Node { return NewLerp() }},
+		{Name: "FBM", New: func() Node { return NewFBM() }},
+		{Name: "Turbulence", New: func() Node { return NewTurbulence() }},
+		{Name: "Swirl", New: func() Node { return NewSwirl() }},
+		{Name: "x", New: func() Node { return NewX() }},
+		{Name: "y", New: func() Node { return NewY() }},
+		{Name: constantName, New: func() Node { return NewConstant() }},
+	}
+
+	for _, spec := range builtIns {
+		spec.Arity = len(spec.New().GetChildren())
+		spec.Weight = 1
+		spec.Enabled = true
+		spec.builtIn = true
+		if err := register(spec); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func register(spec OperatorSpec) error {
+	if existing, ok := operatorsByName[spec.Name]; ok {
+		if existing.builtIn {
+			return fmt.Errorf("operator %s collides with a built-in operator", spec.Name)
+		}
+		return fmt.Errorf("operator %s is already registered", spec.Name)
+	}
+	s := &spec
+	if s.New != nil {
+		typ := reflect.TypeOf(s.New())
+		if other, ok := operatorsByType[typ]; ok {
+			return fmt.Errorf("operator %s uses the same node type as %s", s.Name, other.Name)
+		}
+		operatorsByType[typ] = s
+	}
+	operators = append(operators, s)
+	operatorsByName[s.Name] = s
+	return nil
+}
+
+// newNode creates a new node for the operator.
+func (spec *OperatorSpec) newNode() Node {
+	if spec.New != nil {
+		return spec.New()
+	}
+	return &OperatorFunc{
+		BaseNode: BaseNode{Children: make([]Node, spec.Arity)},
+		spec:     spec,
+	}
+}
+
+// NewNode creates a node for the named operator, with nil children. A new
+// constant gets a random value.
+func NewNode(name string) (Node, error) {
+	spec, ok := operatorsByName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %q", name)
+	}
+	return spec.newNode(), nil
+}
+
+// NodeName returns the name of the operator of node, as used in the .apt
+// format and in the registry (constants are named "Constant").
+func NodeName(node Node) string {
+	if _, ok := node.(*OperatorPicture); ok {
+		return "Picture"
+	}
+	return specOf(node).Name
+}
+
+// Operators returns a copy of the specs of all registered operators, in registration order.
+func Operators() []OperatorSpec {
+	specs := make([]OperatorSpec, len(operators))
+	for i, spec := range operators {
+		specs[i] = *spec
+	}
+	return specs
+}
+
+// LookupOperator returns the spec of the operator with the given name.
+func LookupOperator(name string) (OperatorSpec, bool) {
+	spec, ok := operatorsByName[name]
+	if !ok {
+		return OperatorSpec{}, false
+	}
+	return *spec, true
+}
+
+// SetWeight sets the selection weight of the named operator.
+func SetWeight(name string, weight float64) error {
+	spec, ok := operatorsByName[name]
+	if !ok {
+		return fmt.Errorf("unknown operator : %s", name)
+	}
+	if weight < 0 {
+		return fmt.Errorf("negative weight for operator %s : %g", name, weight)
+	}
+	spec.Weight = weight
+	return nil
+}
+
+// SetEnabled enables or disables the named operator in random generation.
+func SetEnabled(name string, enabled bool) error {
+	spec, ok := operatorsByName[name]
+	if !ok {
+		return fmt.Errorf("unknown operator : %s", name)
+	}
+	spec.Enabled = enabled
+	return nil
+}
+
+// LoadOperatorConfig reads operator settings, one "name = value" per line,
+// where value is either a weight or one of on/off. Empty lines and lines
+// starting with # are ignored. For example :
+//
+//	# Smoother images
+//	Floor = off
+//	Ceil = off
+//	SimplexNoise = 3
+//
+// Settings that leave no leaf or no operator to generate trees with are an
+// error (see CheckWeights). The registry is only changed once the whole
+// file is read and checked, so it is left as it was on errors.
+func LoadOperatorConfig(r io.Reader) error {
+	enabled := make(map[string]bool)
+	weights := make(map[string]float64)
+	err := parseOperatorConfig(r, func(name, value string) error {
+		if _, ok := operatorsByName[name]; !ok {
+			return fmt.Errorf("unknown operator : %s", name)
+		}
+		switch value {
+		case "on":
+			enabled[name] = true
+		case "off":
+			enabled[name] = false
+		default:
+			weight, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			if weight < 0 {
+				return fmt.Errorf("negative weight for operator %s : %g", name, weight)
+			}
+			weights[name] = weight
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = checkTotalWeights(func(spec *OperatorSpec) float64 {
+		on, ok := enabled[spec.Name]
+		if !ok {
+			on = spec.Enabled
+		}
+		if !on {
+			return 0
+		}
+		if weight, ok := weights[spec.Name]; ok {
+			return weight
+		}
+		return spec.Weight
+	})
+	if err != nil {
+		return err
+	}
+	for name, on := range enabled {
+		operatorsByName[name].Enabled = on
+	}
+	for name, weight := range weights {
+		operatorsByName[name].Weight = weight
+	}
+	return nil
+}
+
+// ReadWeights reads operator settings in the format of LoadOperatorConfig
+// into a map of weights (off is a weight of 0, on is the registry weight),
+// without changing the registry. The map can be used with Generator.SetWeight.
+func ReadWeights(r io.Reader) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	err := parseOperatorConfig(r, func(name, value string) error {
+		spec, ok := operatorsByName[name]
+		if !ok {
+			return fmt.Errorf("unknown operator : %s", name)
+		}
+		switch value {
+		case "on":
+			weights[name] = spec.Weight
+		case "off":
+			weights[name] = 0
+		default:
+			weight, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			if weight < 0 {
+				return fmt.Errorf("negative weight for operator %s : %g", name, weight)
+			}
+			weights[name] = weight
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckWeights(weights); err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// CheckWeights returns an error if no enabled leaf, or no enabled operator,
+// has a positive weight, since random trees can't be generated then. The
+// weights override the registry weights, like Generator.SetWeight, and can
+// be nil.
+func CheckWeights(weights map[string]float64) error {
+	g := Generator{weights: weights}
+	return checkTotalWeights(g.weight)
+}
+
+// checkTotalWeights is CheckWeights, with the weight of each operator
+// given by weight.
+func checkTotalWeights(weight func(spec *OperatorSpec) float64) error {
+	leaves, others := 0.0, 0.0
+	for _, spec := range operators {
+		if spec.Arity == 0 {
+			leaves += weight(spec)
+		} else {
+			others += weight(spec)
+		}
+	}
+	if leaves <= 0 {
+		return fmt.Errorf("no enabled leaf has a positive weight")
+	}
+	if others <= 0 {
+		return fmt.Errorf("no enabled operator has a positive weight")
+	}
+	return nil
+}
+
+// parseOperatorConfig calls apply for every "name = value" line in r.
+func parseOperatorConfig(r io.Reader, apply func(name, value string) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d : expected name = value", lineNumber)
+		}
+		if err := apply(strings.TrimSpace(name), strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("line %d : %w", lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// specOf returns the spec of the operator that node is an instance of.
+func specOf(node Node) *OperatorSpec {
+	if op, ok := node.(*OperatorFunc); ok {
+		return op.spec
+	}
+	spec, ok := operatorsByType[reflect.TypeOf(node)]
+	if !ok {
+		panic(fmt.Sprintf("unregistered operator type : %T", node))
+	}
+	return spec
+}
+
+// FormatOperator returns the string representation of an operator
+// node, "( Name child1 child2 ... )", or just the name for leaves.
+func FormatOperator(node Node) string {
+	spec := specOf(node)
+	if len(node.GetChildren()) == 0 {
+		return spec.Name
+	}
+
+	s := "( " + spec.Name
+	for _, child := range node.GetChildren() {
+		s += " " + child.String()
+	}
+	return s + " )"
+}
+
+// totalWeight returns the sum of the weights of the enabled
+// leaves (leaves == true) or the enabled operators.
+func (g *Generator) totalWeight(leaves bool) float64 {
+	total := 0.0
+	for _, spec := range operators {
+		if (spec.Arity == 0) == leaves {
+			total += g.weight(spec)
+		}
+	}
+	return total
+}
+
+// pickRandom creates a node of a random enabled leaf (leaves == true)
+// or operator, with the chance of each one proportional to its weight.
+func (g *Generator) pickRandom(leaves bool) Node {
+	total := g.totalWeight(leaves)
+	if total <= 0 {
+		if leaves {
+			panic("no enabled leaves to pick from")
+		}
+		panic("no enabled operators to pick from")
+	}
+
+	r := g.rand.Float64() * total
+	var picked *OperatorSpec
+	for _, spec := range operators {
+		weight := g.weight(spec)
+		if (spec.Arity == 0) != leaves || weight <= 0 {
+			continue
+		}
+		picked = spec
+		if r < weight {
+			break
+		}
+		// Rounding errors can leave a tiny rest, then the last one is picked
+		r -= weight
+	}
+
+	node := picked.newNode()
+	if c, ok := node.(*OperatorConstant); ok {
+		c.Value = g.rand.Float64()*2 - 1
+	}
+	return node
+}
@@ -0,0 +1,62 @@
+package apt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadOperatorConfigNeedsLeaves(t *testing.T) {
+	saved := Operators()
+	defer func() {
+		for _, spec := range saved {
+			SetEnabled(spec.Name, spec.Enabled)
+			SetWeight(spec.Name, spec.Weight)
+		}
+	}()
+
+	var config strings.Builder
+	for _, spec := range saved {
+		if spec.Arity == 0 {
+			config.WriteString(spec.Name + " = off\n")
+		}
+	}
+	if err := LoadOperatorConfig(strings.NewReader(config.String())); err == nil {
+		t.Error("LoadOperatorConfig turned off all leaves without an error")
+	}
+	if _, err := ReadWeights(strings.NewReader(config.String())); err == nil {
+		t.Error("ReadWeights turned off all leaves without an error")
+	}
+	for i, spec := range Operators() {
+		if spec.Enabled != saved[i].Enabled {
+			t.Errorf("LoadOperatorConfig turned off %s, although the file was rejected", spec.Name)
+		}
+	}
+}
+
+func TestLoadOperatorConfigTurnsOn(t *testing.T) {
+	saved := Operators()
+	defer func() {
+		for _, spec := range saved {
+			SetEnabled(spec.Name, spec.Enabled)
+			SetWeight(spec.Name, spec.Weight)
+		}
+	}()
+
+	// Turning off all the leaves is fine if one is turned on again
+	var config strings.Builder
+	for _, spec := range saved {
+		if spec.Arity == 0 {
+			config.WriteString(spec.Name + " = off\n")
+		}
+	}
+	config.WriteString("x = on\nx = 2\n")
+	if err := LoadOperatorConfig(strings.NewReader(config.String())); err != nil {
+		t.Fatal(err)
+	}
+	if spec, _ := LookupOperator("x"); !spec.Enabled || spec.Weight != 2 {
+		t.Errorf("x is %v with weight %g, want on with weight 2", spec.Enabled, spec.Weight)
+	}
+	if spec, _ := LookupOperator("y"); spec.Enabled {
+		t.Error("y is still on")
+	}
+}
@@ -0,0 +1,459 @@
+// The functions below are ports of the Go math package and of the noise
+// package, so that a picture is computed with exactly the same floating
+// point operations as the Go renderer, and gives the same pixels.
+
+const view = new DataView(new ArrayBuffer(8));
+
+function float64bits(x) {
+	view.setFloat64(0, x);
+	return view.getBigUint64(0);
+}
+
+function float64frombits(b) {
+	view.setBigUint64(0, b);
+	return view.getFloat64(0);
+}
+
+const u64 = (b) => BigInt.asUintN(64, b);
+
+function signbit(x) {
+	return x < 0 || Object.is(x, -0);
+}
+
+function copysign(f, sign) {
+	return signbit(sign) ? -Math.abs(f) : Math.abs(f);
+}
+
+const sinCoefficients = [
+	1.58962301576546568060e-10,
+	-2.50507477628578072866e-8,
+	2.75573136213857245213e-6,
+	-1.98412698295895385996e-4,
+	8.33333333332211858878e-3,
+	-1.66666666666666307295e-1,
+];
+
+const cosCoefficients = [
+	-1.13585365213876817300e-11,
+	2.08757008419747316778e-9,
+	-2.75573141792967388112e-7,
+	2.48015872888517045348e-5,
+	-1.38888888888730564116e-3,
+	4.16666666666665929218e-2,
+];
+
+// The binary digits of 4/pi, used by trigReduce.
+const mPi4 = [
+	0x0000000000000001n,
+	0x45f306dc9c882a53n,
+	0xf84eafa3ea69bb81n,
+	0xb6c52b3278872083n,
+	0xfca2c757bd778ac3n,
+	0x6e48dc74849ba5c0n,
+	0x0c925dd413a32439n,
+	0xfc3bd63962534e7dn,
+	0xd1046bea5d768909n,
+	0xd338e04d68befc82n,
+	0x7323ac7306a673e9n,
+	0x3908bf177bf25076n,
+	0x3ff12fffbc0b301fn,
+	0xde5e2316b414da3en,
+	0xda6cfd9e4f96136en,
+	0x9e8c7ecd3cbfd45an,
+	0xea4f758fd7cbe2f6n,
+	0x7a0e73ef14a525d4n,
+	0xd7f6bf623f1aba10n,
+	0xac06608df8f6d757n,
+];
+
+const reduceThreshold = 536870912; // 1 << 29
+
+function leadingZeros64(b) {
+	return b === 0n ? 64n : BigInt(64 - b.toString(2).length);
+}
+
+// trigReduce is the Payne-Hanek range reduction of large arguments by
+// Pi/4, for x > 0. It returns the octant and the reduced argument.
+function trigReduce(x) {
+	const PI4 = 0.7853981633974483;
+	if (x < PI4) {
+		return [0, x];
+	}
+	let ix = float64bits(x);
+	const exp = Number((ix >> 52n) & 0x7ffn) - 1023 - 52;
+	ix &= ~(0x7ffn << 52n);
+	ix |= 1n << 52n;
+	const digit = Math.floor((exp + 61) / 64);
+	const bitshift = BigInt((exp + 61) % 64);
+	const z0 = u64(mPi4[digit] << bitshift) | (mPi4[digit + 1] >> (64n - bitshift));
+	const z1 = u64(mPi4[digit + 1] << bitshift) | (mPi4[digit + 2] >> (64n - bitshift));
+	const z2 = u64(mPi4[digit + 2] << bitshift) | (mPi4[digit + 3] >> (64n - bitshift));
+	const z2hi = (z2 * ix) >> 64n;
+	const z1ix = z1 * ix;
+	const z1hi = z1ix >> 64n;
+	const z1lo = u64(z1ix);
+	const z0lo = u64(z0 * ix);
+	const sum = z1lo + z2hi;
+	const lo = u64(sum);
+	let hi = u64(z0lo + z1hi + (sum >> 64n));
+	let j = Number(hi >> 61n);
+	hi = u64(hi << 3n) | (lo >> 61n);
+	const lz = leadingZeros64(hi);
+	const e = u64(1023n - (lz + 1n));
+	hi = u64(hi << (lz + 1n)) | (lz + 1n > 64n ? 0n : lo >> (64n - (lz + 1n)));
+	hi >>= 12n;
+	hi = u64(hi | (e << 52n));
+	let z = float64frombits(hi);
+	if ((j & 1) === 1) {
+		j = (j + 1) & 7;
+		z--;
+	}
+	return [j, z * PI4];
+}
+
+// reduce returns the octant and the reduced argument of x >= 0, like the
+// start of the Go sin and cos functions.
+function reduce(x) {
+	const PI4A = 7.85398125648498535156e-1;
+	const PI4B = 3.77489470793079817668e-8;
+	const PI4C = 2.69515142907905952645e-15;
+	if (x >= reduceThreshold) {
+		return trigReduce(x);
+	}
+	let j = Math.trunc(x * 1.2732395447351628);
+	let y = j;
+	if ((j & 1) === 1) {
+		j++;
+		y++;
+	}
+	j &= 7;
+	return [j, ((x - y * PI4A) - y * PI4B) - y * PI4C];
+}
+
+function sinSeries(z, zz) {
+	const c = sinCoefficients;
+	return z + z * zz * ((((((c[0] * zz) + c[1]) * zz + c[2]) * zz + c[3]) * zz + c[4]) * zz + c[5]);
+}
+
+function cosSeries(zz) {
+	const c = cosCoefficients;
+	return 1.0 - 0.5 * zz + zz * zz * ((((((c[0] * zz) + c[1]) * zz + c[2]) * zz + c[3]) * zz + c[4]) * zz + c[5]);
+}
+
+function sin(x) {
+	if (x === 0 || Number.isNaN(x)) {
+		return x;
+	}
+	if (!Number.isFinite(x)) {
+		return NaN;
+	}
+	let sign = false;
+	if (x < 0) {
+		x = -x;
+		sign = true;
+	}
+	let [j, z] = reduce(x);
+	if (j > 3) {
+		sign = !sign;
+		j -= 4;
+	}
+	const zz = z * z;
+	const y = j === 1 || j === 2 ? cosSeries(zz) : sinSeries(z, zz);
+	return sign ? -y : y;
+}
+
+function cos(x) {
+	if (!Number.isFinite(x)) {
+		return NaN;
+	}
+	let sign = false;
+	let [j, z] = reduce(Math.abs(x));
+	if (j > 3) {
+		j -= 4;
+		sign = !sign;
+	}
+	if (j > 1) {
+		sign = !sign;
+	}
+	const zz = z * z;
+	const y = j === 1 || j === 2 ? sinSeries(z, zz) : cosSeries(zz);
+	return sign ? -y : y;
+}
+
+function xatan(x) {
+	const P0 = -8.750608600031904122785e-01;
+	const P1 = -1.615753718733365076637e+01;
+	const P2 = -7.500855792314704667340e+01;
+	const P3 = -1.228866684490136173410e+02;
+	const P4 = -6.485021904942025371773e+01;
+	const Q0 = +2.485846490142306297962e+01;
+	const Q1 = +1.650270098316988542046e+02;
+	const Q2 = +4.328810604912902668951e+02;
+	const Q3 = +4.853903996359136964868e+02;
+	const Q4 = +1.945506571482613964425e+02;
+	let z = x * x;
+	z = z * ((((P0 * z + P1) * z + P2) * z + P3) * z + P4) / (((((z + Q0) * z + Q1) * z + Q2) * z + Q3) * z + Q4);
+	return x * z + x;
+}
+
+function satan(x) {
+	const Morebits = 6.123233995736765886130e-17;
+	const Tan3pio8 = 2.41421356237309504880;
+	if (x <= 0.66) {
+		return xatan(x);
+	}
+	if (x > Tan3pio8) {
+		return 1.5707963267948966 - xatan(1 / x) + Morebits;
+	}
+	return 0.7853981633974483 + xatan((x - 1) / (x + 1)) + 3.061616997868383e-17;
+}
+
+function atan(x) {
+	if (x === 0) {
+		return x;
+	}
+	return x > 0 ? satan(x) : -satan(-x);
+}
+
+function atan2(y, x) {
+	const Pi = 3.141592653589793;
+	if (Number.isNaN(y) || Number.isNaN(x)) {
+		return NaN;
+	}
+	if (y === 0) {
+		if (x >= 0 && !signbit(x)) {
+			return copysign(0, y);
+		}
+		return copysign(Pi, y);
+	}
+	if (x === 0) {
+		return copysign(1.5707963267948966, y);
+	}
+	if (!Number.isFinite(x)) {
+		if (x > 0) {
+			return Number.isFinite(y) ? copysign(0, y) : copysign(0.7853981633974483, y);
+		}
+		return Number.isFinite(y) ? copysign(Pi, y) : copysign(2.356194490192345, y);
+	}
+	if (!Number.isFinite(y)) {
+		return copysign(1.5707963267948966, y);
+	}
+	const q = atan(y / x);
+	if (x < 0) {
+		return q <= 0 ? q + Pi : q - Pi;
+	}
+	return q;
+}
+
+function frexp(f) {
+	if (f === 0 || !Number.isFinite(f)) {
+		return [f, 0];
+	}
+	let exp = 0;
+	if (Math.abs(f) < 2.2250738585072014e-308) {
+		f *= 4503599627370496; // 1 << 52
+		exp = -52;
+	}
+	let x = float64bits(f);
+	exp += Number((x >> 52n) & 0x7ffn) - 1023 + 1;
+	x &= ~(0x7ffn << 52n);
+	x |= 1022n << 52n;
+	return [float64frombits(x), exp];
+}
+
+function log(x) {
+	const Ln2Hi = 6.93147180369123816490e-01;
+	const Ln2Lo = 1.90821492927058770002e-10;
+	const L1 = 6.666666666666735130e-01;
+	const L2 = 3.999999999940941908e-01;
+	const L3 = 2.857142874366239149e-01;
+	const L4 = 2.222219843214978396e-01;
+	const L5 = 1.818357216161805012e-01;
+	const L6 = 1.531383769920937332e-01;
+	const L7 = 1.479819860511658591e-01;
+	if (Number.isNaN(x) || x === Infinity) {
+		return x;
+	}
+	if (x < 0) {
+		return NaN;
+	}
+	if (x === 0) {
+		return -Infinity;
+	}
+	let [f1, ki] = frexp(x);
+	if (f1 < 0.7071067811865476) {
+		f1 *= 2;
+		ki--;
+	}
+	const f = f1 - 1;
+	const k = ki;
+	const s = f / (2 + f);
+	const s2 = s * s;
+	const s4 = s2 * s2;
+	const t1 = s2 * (L1 + s4 * (L3 + s4 * (L5 + s4 * L7)));
+	const t2 = s4 * (L2 + s4 * (L4 + s4 * L6));
+	const R = t1 + t2;
+	const hfsq = 0.5 * f * f;
+	return k * Ln2Hi - ((hfsq - (s * (hfsq + R) + k * Ln2Lo)) - f);
+}
+
+function log2(x) {
+	const [frac, exp] = frexp(x);
+	if (frac === 0.5) {
+		return exp - 1;
+	}
+	return log(frac) * 1.4426950408889634 + exp;
+}
+
+function clip(value, maxVal) {
+	maxVal = Math.abs(maxVal);
+	if (value > maxVal) {
+		return maxVal;
+	} else if (value < -maxVal) {
+		return -maxVal;
+	}
+	return value;
+}
+
+function wrap(f) {
+	const temp = (f - -1.0) / (2.0);
+	return -1.0 + 2.0 * (temp - Math.floor(temp));
+}
+
+function grad2(hash, x, y) {
+	const h = hash & 7;
+	let u = y;
+	let v = 2 * x;
+	if (h < 4) {
+		u = x;
+		v = 2 * y;
+	}
+	if ((h & 1) !== 0) {
+		u = -u;
+	}
+	if ((h & 2) !== 0) {
+		v = -v;
+	}
+	return u + v;
+}
+
+const maxInt64 = 9223372036854775808;
+
+// fastFloor returns the floor of x as a 64-bit Go int, which is
+// math.MinInt64 for NaN and values out of range.
+function fastFloor(x) {
+	const i = x >= -maxInt64 && x < maxInt64 ? BigInt(Math.trunc(x)) : -(1n << 63n);
+	if (Number(i) <= x) {
+		return i;
+	}
+	return BigInt.asIntN(64, i - 1n);
+}
+
+function snoise2(x, y) {
+	const F2 = 0.366025403;
+	const G2 = 0.211324865;
+	let n0, n1, n2;
+
+	const s = (x + y) * F2;
+	const xs = x + s;
+	const ys = y + s;
+	let fi, fj, t, ii, jj;
+	if (Math.abs(xs) < 4503599627370496 && Math.abs(ys) < 4503599627370496) {
+		// The integers are exact as numbers
+		const i = Math.floor(xs) + 0;
+		const j = Math.floor(ys) + 0;
+		fi = i;
+		fj = j;
+		t = (i + j) * G2;
+		ii = i & 255;
+		jj = j & 255;
+	} else {
+		const i = fastFloor(xs);
+		const j = fastFloor(ys);
+		fi = Number(i);
+		fj = Number(j);
+		t = Number(BigInt.asIntN(64, i + j)) * G2;
+		ii = Number(BigInt.asUintN(8, i));
+		jj = Number(BigInt.asUintN(8, j));
+	}
+	const X0 = fi - t;
+	const Y0 = fj - t;
+	const x0 = x - X0;
+	const y0 = y - Y0;
+
+	let i1, j1;
+	if (x0 > y0) {
+		i1 = 1;
+		j1 = 0;
+	} else {
+		i1 = 0;
+		j1 = 1;
+	}
+
+	const x1 = x0 - i1 + G2;
+	const y1 = y0 - j1 + G2;
+	const x2 = x0 - 1.0 + 0.42264973;
+	const y2 = y0 - 1.0 + 0.42264973;
+
+	let t0 = 0.5 - x0 * x0 - y0 * y0;
+	if (t0 < 0.0) {
+		n0 = 0.0;
+	} else {
+		t0 *= t0;
+		n0 = t0 * t0 * grad2(perm[(ii + perm[jj]) & 255], x0, y0);
+	}
+
+	let t1 = 0.5 - x1 * x1 - y1 * y1;
+	if (t1 < 0.0) {
+		n1 = 0.0;
+	} else {
+		t1 *= t1;
+		n1 = t1 * t1 * grad2(perm[(ii + i1 + perm[(jj + j1) & 255]) & 255], x1, y1);
+	}
+
+	let t2 = 0.5 - x2 * x2 - y2 * y2;
+	if (t2 < 0.0) {
+		n2 = 0.0;
+	} else {
+		t2 *= t2;
+		n2 = t2 * t2 * grad2(perm[(ii + 1 + perm[(jj + 1) & 255]) & 255], x2, y2);
+	}
+
+	return n0 + n1 + n2;
+}
+
+function fbm2(x, y, frequency, lacunarity, gain, octaves) {
+	let sum = 0;
+	let amplitude = 1.0;
+	for (let i = 0; i < octaves; i++) {
+		sum += snoise2(x * frequency, y * frequency) * amplitude;
+		frequency = frequency * lacunarity;
+		amplitude = amplitude * gain;
+	}
+	return sum;
+}
+
+function turbulence(x, y, frequency, lacunarity, gain, octaves) {
+	let sum = 0;
+	let amplitude = 1;
+	for (let i = 0; i < octaves; i++) {
+		let f = snoise2(x * frequency, y * frequency) * amplitude;
+		if (f < 0) {
+			f = -1.0 * f;
+		}
+		sum += f;
+		frequency *= lacunarity;
+		amplitude *= gain;
+	}
+	return sum;
+}
+
+// toByte converts the value of a channel to a color component, like
+// picture.ToByte.
+function toByte(value) {
+	const v = value * 128 - -128;
+	if (!(v > -2147483649 && v < 2147483648)) {
+		return 0;
+	}
+	return Math.trunc(v) & 255;
+}
@@ -0,0 +1,473 @@
+package apt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hultan/evolvingImage/noise"
+)
+
+// ShaderLanguage is a shading language that pictures can be exported to.
+type ShaderLanguage int
+
+const (
+	// GLSL is GLSL ES 3.00, as used by WebGL 2 and most shader playgrounds.
+	GLSL ShaderLanguage = iota
+	// WGSL is the WebGPU shading language.
+	WGSL
+)
+
+var shaderLanguageNames = []string{"glsl", "wgsl"}
+
+func (l ShaderLanguage) String() string {
+	if l < 0 || int(l) >= len(shaderLanguageNames) {
+		return fmt.Sprintf("ShaderLanguage(%d)", int(l))
+	}
+	return shaderLanguageNames[l]
+}
+
+// ParseShaderLanguage converts a name returned by ShaderLanguage.String back into a ShaderLanguage.
+func ParseShaderLanguage(s string) (ShaderLanguage, error) {
+	for i, name := range shaderLanguageNames {
+		if name == s {
+			return ShaderLanguage(i), nil
+		}
+	}
+	return GLSL, fmt.Errorf("unknown shader language : %s", s)
+}
+
+// shaderTemplates are the expressions of the operators in GLSL, see expand.
+// They follow the Evaluate methods, but the GPU computes them with 32-bit
+// floats, so the results are close to those of the interpreter, not exact.
+var shaderTemplates = map[string]string{
+	"Swirl":        "sin(6.0*cos($0) - $1*$0)",
+	"FBM":          "2.0*3.627*fbm2($0, $1, 5.0*$2, 0.5, 2.0, 3) + 0.492 - 1.0",
+	"Turbulence":   "2.0*6.96*turbulence($0, $1, 5.0*$2, 0.5, 2.0, 3) - 1.0",
+	"Lerp":         "$0 + $2*($1-$0)",
+	"+":            "$0 + $1",
+	"-":            "$0 - $1",
+	"*":            "$0 * $1",
+	"/":            "$0 / $1",
+	"Atan2":        "atan(y, x)",
+	"SimplexNoise": "80.0*snoise2($0, $1) - 2.0",
+	"Clip":         "clip($0, $1)",
+	"Square":       "$0 * $0",
+	"Log2":         "log2($0)",
+	"Negate":       "-$0",
+	"Ceil":         "ceil($0)",
+	"Floor":        "floor($0)",
+	"Abs":          "abs($0)",
+	"Wrap":         "wrap($0)",
+	"Sin":          "sin($0)",
+	"Cos":          "cos($0)",
+	"Atan":         "atan($0)",
+}
+
+// wgslTemplates are the expressions that differ in WGSL.
+var wgslTemplates = map[string]string{
+	"Atan2": "atan2(y, x)",
+}
+
+// GenerateShader generates a self-contained fragment shader, that draws
+// the picture with the channels r, g and b over the whole viewport. The
+// coordinates are mapped like in the renderer, from (-1, -1) in the top
+// left corner, and the color components are clamped to [0, 1]. The
+// shader expects the size of the viewport in pixels in the uniform
+// "resolution". Registered operators need code in the language (see
+// OperatorCode).
+func GenerateShader(r, g, b Node, language ShaderLanguage) (string, error) {
+	p, err := lower(r, g, b)
+	if err != nil {
+		return "", err
+	}
+
+	var body strings.Builder
+	operands := make([]string, len(p.instrs))
+	for i, in := range p.instrs {
+		switch in.op {
+		case "x", "y":
+			operands[i] = in.op
+			continue
+		case constantName:
+			literal, err := shaderLiteral(in.value)
+			if err != nil {
+				return "", err
+			}
+			if language != WGSL {
+				operands[i] = literal
+				continue
+			}
+			// WGSL rejects constant expressions that are NaN or infinite (like the
+			// log2 of a negative constant), so constants are variables instead
+			operands[i] = fmt.Sprintf("v%d", i)
+			fmt.Fprintf(&body, "\tlet %s = %s;\n", operands[i], literal)
+			continue
+		}
+
+		template, ok := shaderTemplates[in.op]
+		if language == WGSL && wgslTemplates[in.op] != "" {
+			template = wgslTemplates[in.op]
+		}
+		if !ok {
+			template, ok = registeredCode(in.op, func(c OperatorCode) string {
+				if language == WGSL {
+					return c.WGSL
+				}
+				return c.GLSL
+			})
+		}
+		if !ok {
+			return "", fmt.Errorf("operator %s can't be converted to %s", in.op, language)
+		}
+		args := make([]string, len(in.args))
+		for j, arg := range in.args {
+			args[j] = operands[arg]
+		}
+		operands[i] = fmt.Sprintf("v%d", i)
+		if language == WGSL {
+			fmt.Fprintf(&body, "\tlet %s = %s;\n", operands[i], expand(template, args))
+		} else {
+			fmt.Fprintf(&body, "\tfloat %s = %s;\n", operands[i], expand(template, args))
+		}
+	}
+
+	var src strings.Builder
+	header, helpers, footer := glslHeader, glslHelpers, glslMain
+	vec3 := "vec3"
+	if language == WGSL {
+		header, helpers, footer = wgslHeader, wgslHelpers, wgslMain
+		vec3 = "vec3<f32>"
+	}
+	src.WriteString(header)
+	if p.uses("SimplexNoise", "FBM", "Turbulence") {
+		src.WriteString(permutationTable(language))
+		src.WriteString(helpers["noise"])
+	}
+	for _, op := range []string{"FBM", "Turbulence", "Clip", "Wrap"} {
+		if p.uses(op) {
+			src.WriteString(helpers[op])
+		}
+	}
+
+	if language == WGSL {
+		src.WriteString("\nfn picture(x: f32, y: f32) -> vec3<f32> {\n")
+	} else {
+		src.WriteString("\nvec3 picture(float x, float y) {\n")
+	}
+	src.WriteString(body.String())
+	fmt.Fprintf(&src, "\treturn %s(%s, %s, %s);\n}\n", vec3, operands[p.outputs[0]], operands[p.outputs[1]], operands[p.outputs[2]])
+	src.WriteString(footer)
+	return src.String(), nil
+}
+
+// shaderLiteral returns a float literal for a constant, which is the same
+// in GLSL and WGSL.
+func shaderLiteral(value float64) (string, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || math.Abs(value) > math.MaxFloat32 {
+		return "", fmt.Errorf("constant %v can't be written in a shader", value)
+	}
+	s := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s, nil
+}
+
+// permutationTable returns the permutation table of the noise functions.
+func permutationTable(language ShaderLanguage) string {
+	perm := noise.Permutation()
+	values := make([]string, len(perm))
+	for i, v := range perm {
+		values[i] = strconv.Itoa(int(v))
+	}
+
+	var sb strings.Builder
+	if language == WGSL {
+		sb.WriteString("\nvar<private> perm: array<i32, 256> = array<i32, 256>(")
+	} else {
+		sb.WriteString("\nconst int perm[256] = int[256](")
+	}
+	for i := 0; i < len(values); i += 16 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n\t" + strings.Join(values[i:i+16], ", "))
+	}
+	sb.WriteString("\n);\n")
+	return sb.String()
+}
+
+// The version must be on the first line in WebGL.
+const glslHeader = `#version 300 es
+// Generated from an evolved picture.
+precision highp float;
+precision highp int;
+
+uniform vec2 resolution;
+out vec4 fragColor;
+`
+
+const glslMain = `
+void main() {
+	// Pixel (0, 0) is in the top left corner, like in the renderer
+	vec2 pixel = vec2(gl_FragCoord.x - 0.5, resolution.y - gl_FragCoord.y - 0.5);
+	vec2 p = pixel / resolution * 2.0 - 1.0;
+	fragColor = vec4(clamp(picture(p.x, p.y) * 0.5 + 0.5, 0.0, 1.0), 1.0);
+}
+`
+
+// glslHelpers are the helper functions, ported from the Evaluate methods
+// and the noise package. Snoise2 indexes its permutation table with bytes,
+// which is done with "& 255" here.
+var glslHelpers = map[string]string{
+	"noise": `
+float grad2(int hash, float x, float y) {
+	int h = hash & 7;
+	float u = y;
+	float v = 2.0 * x;
+	if (h < 4) {
+		u = x;
+		v = 2.0 * y;
+	}
+	if ((h & 1) != 0) {
+		u = -u;
+	}
+	if ((h & 2) != 0) {
+		v = -v;
+	}
+	return u + v;
+}
+
+float snoise2(float x, float y) {
+	const float F2 = 0.366025403;
+	const float G2 = 0.211324865;
+	float n0, n1, n2;
+
+	float s = (x + y) * F2;
+	int i = int(floor(x + s));
+	int j = int(floor(y + s));
+	float t = float(i + j) * G2;
+	float x0 = x - (float(i) - t);
+	float y0 = y - (float(j) - t);
+
+	int i1 = 0;
+	int j1 = 1;
+	if (x0 > y0) {
+		i1 = 1;
+		j1 = 0;
+	}
+
+	float x1 = x0 - float(i1) + G2;
+	float y1 = y0 - float(j1) + G2;
+	float x2 = x0 - 1.0 + 2.0 * G2;
+	float y2 = y0 - 1.0 + 2.0 * G2;
+	int ii = i & 255;
+	int jj = j & 255;
+
+	float t0 = 0.5 - x0 * x0 - y0 * y0;
+	if (t0 < 0.0) {
+		n0 = 0.0;
+	} else {
+		t0 *= t0;
+		n0 = t0 * t0 * grad2(perm[(ii + perm[jj]) & 255], x0, y0);
+	}
+	float t1 = 0.5 - x1 * x1 - y1 * y1;
+	if (t1 < 0.0) {
+		n1 = 0.0;
+	} else {
+		t1 *= t1;
+		n1 = t1 * t1 * grad2(perm[(ii + i1 + perm[(jj + j1) & 255]) & 255], x1, y1);
+	}
+	float t2 = 0.5 - x2 * x2 - y2 * y2;
+	if (t2 < 0.0) {
+		n2 = 0.0;
+	} else {
+		t2 *= t2;
+		n2 = t2 * t2 * grad2(perm[(ii + 1 + perm[(jj + 1) & 255]) & 255], x2, y2);
+	}
+	return n0 + n1 + n2;
+}
+`,
+	"FBM": `
+float fbm2(float x, float y, float frequency, float lacunarity, float gain, int octaves) {
+	float sum = 0.0;
+	float amplitude = 1.0;
+	for (int i = 0; i < octaves; i++) {
+		sum += snoise2(x * frequency, y * frequency) * amplitude;
+		frequency *= lacunarity;
+		amplitude *= gain;
+	}
+	return sum;
+}
+`,
+	"Turbulence": `
+float turbulence(float x, float y, float frequency, float lacunarity, float gain, int octaves) {
+	float sum = 0.0;
+	float amplitude = 1.0;
+	for (int i = 0; i < octaves; i++) {
+		float f = snoise2(x * frequency, y * frequency) * amplitude;
+		if (f < 0.0) {
+			f = -1.0 * f;
+		}
+		sum += f;
+		frequency *= lacunarity;
+		amplitude *= gain;
+	}
+	return sum;
+}
+`,
+	"Clip": `
+float clip(float value, float maxVal) {
+	maxVal = abs(maxVal);
+	if (value > maxVal) {
+		return maxVal;
+	} else if (value < -maxVal) {
+		return -maxVal;
+	}
+	return value;
+}
+`,
+	"Wrap": `
+float wrap(float f) {
+	float temp = (f - -1.0) / 2.0;
+	return -1.0 + 2.0 * (temp - floor(temp));
+}
+`,
+}
+
+const wgslHeader = `// Generated from an evolved picture.
+
+@group(0) @binding(0) var<uniform> resolution: vec2<f32>;
+`
+
+const wgslMain = `
+@fragment
+fn main(@builtin(position) position: vec4<f32>) -> @location(0) vec4<f32> {
+	// Pixel (0, 0) is in the top left corner, like in the renderer
+	let p = (position.xy - 0.5) / resolution * 2.0 - 1.0;
+	return vec4<f32>(clamp(picture(p.x, p.y) * 0.5 + 0.5, vec3<f32>(0.0), vec3<f32>(1.0)), 1.0);
+}
+`
+
+// wgslHelpers are the helper functions in WGSL, see glslHelpers.
+var wgslHelpers = map[string]string{
+	"noise": `
+fn grad2(hash: i32, x: f32, y: f32) -> f32 {
+	let h = hash & 7;
+	var u = y;
+	var v = 2.0 * x;
+	if (h < 4) {
+		u = x;
+		v = 2.0 * y;
+	}
+	if ((h & 1) != 0) {
+		u = -u;
+	}
+	if ((h & 2) != 0) {
+		v = -v;
+	}
+	return u + v;
+}
+
+fn snoise2(x: f32, y: f32) -> f32 {
+	let F2 = 0.366025403;
+	let G2 = 0.211324865;
+	var n0: f32;
+	var n1: f32;
+	var n2: f32;
+
+	let s = (x + y) * F2;
+	let i = i32(floor(x + s));
+	let j = i32(floor(y + s));
+	let t = f32(i + j) * G2;
+	let x0 = x - (f32(i) - t);
+	let y0 = y - (f32(j) - t);
+
+	var i1 = 0;
+	var j1 = 1;
+	if (x0 > y0) {
+		i1 = 1;
+		j1 = 0;
+	}
+
+	let x1 = x0 - f32(i1) + G2;
+	let y1 = y0 - f32(j1) + G2;
+	let x2 = x0 - 1.0 + 2.0 * G2;
+	let y2 = y0 - 1.0 + 2.0 * G2;
+	let ii = i & 255;
+	let jj = j & 255;
+
+	var t0 = 0.5 - x0 * x0 - y0 * y0;
+	if (t0 < 0.0) {
+		n0 = 0.0;
+	} else {
+		t0 *= t0;
+		n0 = t0 * t0 * grad2(perm[(ii + perm[jj]) & 255], x0, y0);
+	}
+	var t1 = 0.5 - x1 * x1 - y1 * y1;
+	if (t1 < 0.0) {
+		n1 = 0.0;
+	} else {
+		t1 *= t1;
+		n1 = t1 * t1 * grad2(perm[(ii + i1 + perm[(jj + j1) & 255]) & 255], x1, y1);
+	}
+	var t2 = 0.5 - x2 * x2 - y2 * y2;
+	if (t2 < 0.0) {
+		n2 = 0.0;
+	} else {
+		t2 *= t2;
+		n2 = t2 * t2 * grad2(perm[(ii + 1 + perm[(jj + 1) & 255]) & 255], x2, y2);
+	}
+	return n0 + n1 + n2;
+}
+`,
+	"FBM": `
+fn fbm2(x: f32, y: f32, frequency: f32, lacunarity: f32, gain: f32, octaves: i32) -> f32 {
+	var sum = 0.0;
+	var amplitude = 1.0;
+	var freq = frequency;
+	for (var i = 0; i < octaves; i++) {
+		sum += snoise2(x * freq, y * freq) * amplitude;
+		freq *= lacunarity;
+		amplitude *= gain;
+	}
+	return sum;
+}
+`,
+	"Turbulence": `
+fn turbulence(x: f32, y: f32, frequency: f32, lacunarity: f32, gain: f32, octaves: i32) -> f32 {
+	var sum = 0.0;
+	var amplitude = 1.0;
+	var freq = frequency;
+	for (var i = 0; i < octaves; i++) {
+		var f = snoise2(x * freq, y * freq) * amplitude;
+		if (f < 0.0) {
+			f = -1.0 * f;
+		}
+		sum += f;
+		freq *= lacunarity;
+		amplitude *= gain;
+	}
+	return sum;
+}
+`,
+	"Clip": `
+fn clip(value: f32, maxVal: f32) -> f32 {
+	let m = abs(maxVal);
+	if (value > m) {
+		return m;
+	} else if (value < -m) {
+		return -m;
+	}
+	return value;
+}
+`,
+	"Wrap": `
+fn wrap(f: f32) -> f32 {
+	let temp = (f - -1.0) / 2.0;
+	return -1.0 + 2.0 * (temp - floor(temp));
+}
+`,
+}
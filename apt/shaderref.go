@@ -0,0 +1,228 @@
+package apt
+
+import (
+	"math"
+
+	"github.com/hultan/evolvingImage/noise"
+)
+
+// CompareFloat32 runs the program that GenerateShader translates into
+// shader code, with 32-bit floats like a GPU, on a size x size grid. It
+// returns the number of color components (out of 3*size*size) that differ
+// by more than tolerance from what Evaluate returns. This checks the
+// translation of the operators and the effect of the lower precision, not
+// the shader text itself, which takes a shader compiler. A few components
+// close to the discontinuities of Floor, Ceil, Wrap and the noise operators
+// can be expected to differ.
+func CompareFloat32(r, g, b Node, size int, tolerance float64) (int, error) {
+	p, err := lower(r, g, b)
+	if err != nil {
+		return 0, err
+	}
+
+	mismatches := 0
+	values := make([]float32, len(p.instrs))
+	for row := 0; row < size; row++ {
+		y := float64(row)/float64(size)*2 - 1
+		for column := 0; column < size; column++ {
+			x := float64(column)/float64(size)*2 - 1
+			p.evaluate32(float32(x), float32(y), values)
+			for i, root := range []Node{r, g, b} {
+				expected := root.Evaluate(x, y)
+				actual := float64(values[p.outputs[i]])
+				if !withinTolerance(expected, actual, tolerance) {
+					mismatches++
+				}
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// withinTolerance compares the values like the colors on screen, where
+// NaN and infinite values are treated as equal to each other.
+func withinTolerance(expected, actual, tolerance float64) bool {
+	if math.IsNaN(expected) || math.IsInf(expected, 0) || math.IsNaN(actual) || math.IsInf(actual, 0) {
+		return math.IsNaN(expected) == math.IsNaN(actual) && math.IsInf(expected, 1) == math.IsInf(actual, 1) &&
+			math.IsInf(expected, -1) == math.IsInf(actual, -1)
+	}
+	return math.Abs(expected-actual) <= tolerance
+}
+
+// evaluate32 runs the program like the generated shader does, with 32-bit floats.
+func (p *program) evaluate32(x, y float32, values []float32) {
+	f := func(v float64) float32 { return float32(v) }
+	for i, in := range p.instrs {
+		var a, b, c float32
+		if len(in.args) > 0 {
+			a = values[in.args[0]]
+		}
+		if len(in.args) > 1 {
+			b = values[in.args[1]]
+		}
+		if len(in.args) > 2 {
+			c = values[in.args[2]]
+		}
+
+		var v float32
+		switch in.op {
+		case "x":
+			v = x
+		case "y":
+			v = y
+		case constantName:
+			v = float32(in.value)
+		case "Swirl":
+			v = f(math.Sin(float64(6*f(math.Cos(float64(a))) - b*a)))
+		case "FBM":
+			v = 2*3.627*fbm32(a, b, 5*c, 0.5, 2, 3) + 0.492 - 1
+		case "Turbulence":
+			v = 2*6.96*turbulence32(a, b, 5*c, 0.5, 2, 3) - 1
+		case "Lerp":
+			v = a + c*(b-a)
+		case "+":
+			v = a + b
+		case "-":
+			v = a - b
+		case "*":
+			v = a * b
+		case "/":
+			v = a / b
+		case "Atan2":
+			v = f(math.Atan2(float64(y), float64(x)))
+		case "SimplexNoise":
+			v = 80*snoise32(a, b) - 2
+		case "Clip":
+			m := float32(math.Abs(float64(b)))
+			v = a
+			if a > m {
+				v = m
+			} else if a < -m {
+				v = -m
+			}
+		case "Square":
+			v = a * a
+		case "Log2":
+			v = f(math.Log2(float64(a)))
+		case "Negate":
+			v = -a
+		case "Ceil":
+			v = f(math.Ceil(float64(a)))
+		case "Floor":
+			v = f(math.Floor(float64(a)))
+		case "Abs":
+			v = f(math.Abs(float64(a)))
+		case "Wrap":
+			temp := (a - -1) / 2
+			v = -1 + 2*(temp-f(math.Floor(float64(temp))))
+		case "Sin":
+			v = f(math.Sin(float64(a)))
+		case "Cos":
+			v = f(math.Cos(float64(a)))
+		case "Atan":
+			v = f(math.Atan(float64(a)))
+		default:
+			// A registered operator, which is evaluated in float64
+			args := make([]float64, len(in.args))
+			for j, arg := range in.args {
+				args[j] = float64(values[arg])
+			}
+			v = f(evaluateRegistered(in.op, float64(x), float64(y), args))
+		}
+		values[i] = v
+	}
+}
+
+// evaluateRegistered evaluates a registered operator, given the values of its children.
+func evaluateRegistered(op string, x, y float64, args []float64) float64 {
+	spec := operatorsByName[op]
+	if spec.Eval != nil {
+		return spec.Eval(x, y, args)
+	}
+	node := spec.New()
+	for i, arg := range args {
+		node.GetChildren()[i] = newConstant(arg)
+	}
+	return node.Evaluate(x, y)
+}
+
+func fbm32(x, y, frequency, lacunarity, gain float32, octaves int) float32 {
+	var sum float32
+	amplitude := float32(1)
+	for i := 0; i < octaves; i++ {
+		sum += snoise32(x*frequency, y*frequency) * amplitude
+		frequency *= lacunarity
+		amplitude *= gain
+	}
+	return sum
+}
+
+func turbulence32(x, y, frequency, lacunarity, gain float32, octaves int) float32 {
+	var sum float32
+	amplitude := float32(1)
+	for i := 0; i < octaves; i++ {
+		f := snoise32(x*frequency, y*frequency) * amplitude
+		if f < 0 {
+			f = -1 * f
+		}
+		sum += f
+		frequency *= lacunarity
+		amplitude *= gain
+	}
+	return sum
+}
+
+// snoise32 is the snoise2 of the generated shaders, see glslHelpers.
+func snoise32(x, y float32) float32 {
+	const F2 float32 = 0.366025403
+	const G2 float32 = 0.211324865
+	perm := noise.Permutation()
+	grad := func(hash int, x, y float32) float32 {
+		h := hash & 7
+		u, v := y, 2*x
+		if h < 4 {
+			u, v = x, 2*y
+		}
+		if h&1 != 0 {
+			u = -u
+		}
+		if h&2 != 0 {
+			v = -v
+		}
+		return u + v
+	}
+	at := func(i int) int { return int(perm[i&255]) }
+
+	s := (x + y) * F2
+	i := int(math.Floor(float64(x + s)))
+	j := int(math.Floor(float64(y + s)))
+	t := float32(i+j) * G2
+	x0 := x - (float32(i) - t)
+	y0 := y - (float32(j) - t)
+
+	i1, j1 := 0, 1
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	}
+	x1 := x0 - float32(i1) + G2
+	y1 := y0 - float32(j1) + G2
+	x2 := x0 - 1 + 2*G2
+	y2 := y0 - 1 + 2*G2
+	ii := i & 255
+	jj := j & 255
+
+	var n0, n1, n2 float32
+	if t0 := 0.5 - x0*x0 - y0*y0; !(t0 < 0) {
+		t0 *= t0
+		n0 = t0 * t0 * grad(at(ii+at(jj)), x0, y0)
+	}
+	if t1 := 0.5 - x1*x1 - y1*y1; !(t1 < 0) {
+		t1 *= t1
+		n1 = t1 * t1 * grad(at(ii+i1+at(jj+j1)), x1, y1)
+	}
+	if t2 := 0.5 - x2*x2 - y2*y2; !(t2 < 0) {
+		t2 *= t2
+		n2 = t2 * t2 * grad(at(ii+1+at(jj+1)), x2, y2)
+	}
+	return n0 + n1 + n2
+}
@@ -0,0 +1,219 @@
+package apt
+
+import "math"
+
+// Simplify returns a simplified copy of a tree (node itself is not changed).
+// Constant subtrees are folded into an OperatorConstant, children that are
+// never evaluated are replaced by constants, and a set of safe identities
+// is applied. Only rewrites that give exactly the same value for every x
+// and y in [-1, 1], where pictures are rendered, are done, so the
+// simplified tree renders identically. Identities
+// that only hold for most values are left alone, for example ( / y y ) is
+// not 1 where y is 0.
+func Simplify(node Node) Node {
+	simplified := simplify(CopyTree(node, nil))
+	simplified.SetParent(nil)
+	return simplified
+}
+
+func simplify(node Node) Node {
+	if _, ok := node.(*OperatorPicture); !ok {
+		removeDeadChildren(node)
+	}
+
+	children := node.GetChildren()
+	for i, child := range children {
+		children[i] = simplify(child)
+		children[i].SetParent(node)
+	}
+
+	if folded, ok := foldConstant(node); ok {
+		return folded
+	}
+	return applyIdentities(node)
+}
+
+// removeDeadChildren replaces the dead children of node with constants.
+func removeDeadChildren(node Node) {
+	for _, i := range deadChildren(node) {
+		node.GetChildren()[i] = newConstant(0)
+	}
+}
+
+// deadChildren returns the indexes of the children that the operator never
+// evaluates. Atan2 only looks at x and y, and Swirl evaluates its first
+// child twice instead of its third child.
+func deadChildren(node Node) []int {
+	switch node.(type) {
+	case *OperatorAtan2:
+		return []int{0, 1}
+	case *OperatorSwirl:
+		return []int{2}
+	}
+	return nil
+}
+
+// foldConstant evaluates an operator whose children are all constants,
+// and that doesn't depend on x or y itself, into a single constant.
+// Results that are not finite are not folded, since they can't be
+// written to an .apt file.
+func foldConstant(node Node) (Node, bool) {
+	children := node.GetChildren()
+	if len(children) == 0 || !isBuiltIn(node) {
+		return nil, false
+	}
+	switch node.(type) {
+	case *OperatorAtan2, *OperatorPicture:
+		return nil, false
+	}
+	for _, child := range children {
+		if _, ok := child.(*OperatorConstant); !ok {
+			return nil, false
+		}
+	}
+
+	value := node.Evaluate(0, 0)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, false
+	}
+	return newConstant(value), true
+}
+
+// applyIdentities rewrites node using identities that are exact for
+// all inputs, including -0, infinities and NaN.
+func applyIdentities(node Node) Node {
+	children := node.GetChildren()
+
+	switch node.(type) {
+	case *OperatorNegate:
+		// Negate(Negate a) = a
+		if inner, ok := children[0].(*OperatorNegate); ok {
+			return inner.Children[0]
+		}
+	case *OperatorAbs:
+		// Abs(Abs a) = Abs a, Abs(Negate a) = Abs a
+		switch inner := children[0].(type) {
+		case *OperatorAbs:
+			return inner
+		case *OperatorNegate:
+			children[0] = inner.Children[0]
+			children[0].SetParent(node)
+		}
+	case *OperatorSquare:
+		// Square(Negate a) = Square a
+		if inner, ok := children[0].(*OperatorNegate); ok {
+			children[0] = inner.Children[0]
+			children[0].SetParent(node)
+		}
+	case *OperatorFloor, *OperatorCeil:
+		// Floor and Ceil of an integer (Floor or Ceil) is the integer itself
+		switch children[0].(type) {
+		case *OperatorFloor, *OperatorCeil:
+			return children[0]
+		}
+	case *OperatorMult:
+		// a * 1 = a, 1 * a = a
+		if isConstant(children[1], 1) {
+			return children[0]
+		}
+		if isConstant(children[0], 1) {
+			return children[1]
+		}
+	case *OperatorDiv:
+		// a / 1 = a
+		if isConstant(children[1], 1) {
+			return children[0]
+		}
+	case *OperatorMinus:
+		// a - 0 = a (but not a + 0, since -0 + 0 = 0)
+		if isConstant(children[1], 0) && !math.Signbit(children[1].(*OperatorConstant).Value) {
+			return children[0]
+		}
+		// a - a = 0, as long as a is always finite
+		if bound(children[0]) < math.Inf(1) && Equal(children[0], children[1]) {
+			return newConstant(0)
+		}
+	}
+	return node
+}
+
+// bound returns the largest absolute value that node can evaluate to,
+// for x and y in [-1, 1], or +Inf if node is not known to be finite.
+func bound(node Node) float64 {
+	// A NaN bound (like 0 * Inf) means that nothing is known
+	if b := nodeBound(node); b < math.Inf(1) {
+		return b
+	}
+	return math.Inf(1)
+}
+
+func nodeBound(node Node) float64 {
+	children := node.GetChildren()
+	childBound := func(i int) float64 {
+		return bound(children[i])
+	}
+
+	switch n := node.(type) {
+	case *OperatorX, *OperatorY:
+		return 1
+	case *OperatorConstant:
+		if math.IsNaN(n.Value) {
+			return math.Inf(1)
+		}
+		return math.Abs(n.Value)
+	case *OperatorSin, *OperatorCos:
+		if math.IsInf(childBound(0), 0) {
+			return math.Inf(1)
+		}
+		return 1
+	case *OperatorAtan:
+		if math.IsInf(childBound(0), 0) {
+			return math.Inf(1)
+		}
+		return math.Pi / 2
+	case *OperatorAtan2:
+		return math.Pi
+	case *OperatorWrap:
+		if math.IsInf(childBound(0), 0) {
+			return math.Inf(1)
+		}
+		return 1
+	case *OperatorAbs, *OperatorNegate:
+		return childBound(0)
+	case *OperatorFloor, *OperatorCeil:
+		return childBound(0) + 1
+	case *OperatorSquare:
+		return childBound(0) * childBound(0)
+	case *OperatorPlus, *OperatorMinus:
+		return childBound(0) + childBound(1)
+	case *OperatorMult:
+		return childBound(0) * childBound(1)
+	case *OperatorClip:
+		if math.IsInf(childBound(0), 0) || math.IsInf(childBound(1), 0) {
+			return math.Inf(1)
+		}
+		return min(childBound(0), childBound(1))
+	case *OperatorLerp:
+		return childBound(0) + childBound(2)*(childBound(0)+childBound(1))
+	default:
+		return math.Inf(1)
+	}
+}
+
+func isBuiltIn(node Node) bool {
+	if _, ok := node.(*OperatorPicture); ok {
+		return true
+	}
+	return specOf(node).builtIn
+}
+
+func isConstant(node Node, value float64) bool {
+	c, ok := node.(*OperatorConstant)
+	return ok && c.Value == value
+}
+
+func newConstant(value float64) *OperatorConstant {
+	c := NewConstant()
+	c.Value = value
+	return c
+}
@@ -0,0 +1,28 @@
+package apt
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimplifyKeepsNaN(t *testing.T) {
+	// 0 * Log2 x is NaN where x is 0, so a - a is not 0 there
+	node := BeginLexing("( - ( * 0 ( Log2 x ) ) ( * 0 ( Log2 x ) ) )")
+	simplified := Simplify(node)
+
+	want := node.Evaluate(0, 0)
+	got := simplified.Evaluate(0, 0)
+	if !math.IsNaN(want) {
+		t.Fatalf("original at (0, 0) = %v, want NaN", want)
+	}
+	if !math.IsNaN(got) {
+		t.Errorf("Simplify(%s) = %s, which is %v at (0, 0), want NaN", node, simplified, got)
+	}
+}
+
+func TestSimplifyMinusSelf(t *testing.T) {
+	node := BeginLexing("( - ( Sin ( * x y ) ) ( Sin ( * x y ) ) )")
+	if simplified := Simplify(node); !isConstant(simplified, 0) {
+		t.Errorf("Simplify(%s) = %s, want 0", node, simplified)
+	}
+}
@@ -2,13 +2,15 @@ package main
 
 import rl "github.com/gen2brain/raylib-go/raylib"
 
-const fontSize = 64
+// fontSize is the size of the text of the text buttons (see -fontsize).
+var fontSize int32 = 64
 
 type Button struct {
 	Index          int32
 	Rectangle      rl.Rectangle
 	Texture        rl.Texture2D
 	Text           string
+	Label          string
 	Selected       bool
 	IsLeftClicked  func()
 	IsRightClicked func(*Button)
@@ -68,19 +70,23 @@ func (b *Button) draw() {
 		// Image Button
 		rl.DrawTexture(b.Texture, int32(b.Rectangle.X), int32(b.Rectangle.Y), rl.White)
 
+		if b.Label != "" {
+			rl.DrawText(b.Label, int32(b.Rectangle.X)+4, int32(b.Rectangle.Y+b.Rectangle.Height)+2, 10, rl.LightGray)
+		}
+
 		if b.Selected {
 			rl.DrawRectangleLinesEx(b.Rectangle, 2, rl.White)
 		}
 	} else {
 		// Text Button
 		rl.DrawRectangleRec(b.Rectangle, rl.White)
-		tw := rl.MeasureTextEx(font, b.Text, fontSize, 0)
+		tw := rl.MeasureTextEx(font, b.Text, float32(fontSize), 0)
 		x := b.Rectangle.X + b.Rectangle.Width/2 - tw.X/2
 		y := b.Rectangle.Y + b.Rectangle.Height/2 - tw.Y/2
 		r := rl.Vector2{
 			X: x,
 			Y: y,
 		}
-		rl.DrawTextEx(font, b.Text, r, fontSize, 0, rl.Black)
+		rl.DrawTextEx(font, b.Text, r, float32(fontSize), 0, rl.Black)
 	}
 }
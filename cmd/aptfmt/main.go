@@ -0,0 +1,172 @@
+// Command aptfmt formats .apt files (see apt.Format).
+//
+// Usage:
+//
+//	aptfmt [flags] [file.apt...]
+//
+// Without files, it formats standard input to standard output. The header
+// and the other comments at the top and at the end of a file are kept as
+// they are.
+// Before anything is written, the formatted text is parsed again, and
+// aptfmt fails if it doesn't give the same tree.
+//
+// The flags are:
+//
+//	-d
+//		Print diffs instead of the formatted files.
+//	-l
+//		List the files whose formatting differs.
+//	-w
+//		Write the result back to the files.
+//	-width n, -indent s, -precision n
+//		The layout, see apt.FormatOptions.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+var (
+	diff  = flag.Bool("d", false, "print diffs instead of the formatted files")
+	list  = flag.Bool("l", false, "list the files whose formatting differs")
+	write = flag.Bool("w", false, "write the result back to the files")
+)
+
+func main() {
+	options := apt.DefaultFormatOptions
+	flag.IntVar(&options.Width, "width", options.Width, "longest line wanted")
+	flag.StringVar(&options.Indent, "indent", options.Indent, "indentation of each level")
+	flag.IntVar(&options.Precision, "precision", options.Precision, "digits after the decimal point of constants (-1 = as many as needed)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage : aptfmt [flags] [file.apt...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "aptfmt : can't use -w on standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, options); err != nil {
+			fmt.Fprintf(os.Stderr, "aptfmt : %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	failed := false
+	for _, fileName := range flag.Args() {
+		file, err := os.Open(fileName)
+		if err == nil {
+			err = processFile(fileName, file, options)
+			file.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "aptfmt : %s\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func processFile(fileName string, r io.Reader, options apt.FormatOptions) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	formatted, err := formatSource(string(src), options)
+	if err != nil {
+		return fmt.Errorf("%s : %w", fileName, err)
+	}
+
+	changed := !bytes.Equal(src, []byte(formatted))
+	if *list && changed {
+		fmt.Println(fileName)
+	}
+	if *write && changed {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(fileName, []byte(formatted), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if *diff && changed {
+		fmt.Print(unifiedDiff(fileName+".orig", fileName, string(src), formatted))
+	}
+	if !*list && !*write && !*diff {
+		fmt.Print(formatted)
+	}
+	return nil
+}
+
+// formatSource formats the contents of an .apt file, keeping the comments
+// at the top and at the end. Comments inside the tree can't be kept, so
+// they are an error.
+func formatSource(src string, options apt.FormatOptions) (string, error) {
+	top, rest := splitTop(src)
+	rest, bottom := splitBottom(rest)
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		line := strings.Count(src[:len(top)+i], "\n") + 1
+		return "", fmt.Errorf("line %d : comments are only kept at the top and at the end of a file", line)
+	}
+
+	_, node, err := apt.ParseFile(src)
+	if err != nil {
+		return "", err
+	}
+	formatted := top + apt.Format(node, options) + strings.TrimLeft(bottom, " \t\r\n")
+
+	// Make sure that the formatted text gives exactly the same tree, which
+	// it doesn't if -precision rounds the constants
+	_, again, err := apt.ParseFile(formatted)
+	if err != nil {
+		return "", fmt.Errorf("the formatted tree doesn't parse : %w", err)
+	}
+	if !apt.Equal(again, node) {
+		return "", fmt.Errorf("the formatted tree differs from the original, try a higher -precision")
+	}
+	return formatted, nil
+}
+
+// splitTop splits src after the comments and blank lines at the top.
+func splitTop(src string) (top, rest string) {
+	pos := 0
+	for pos < len(src) {
+		end := strings.IndexByte(src[pos:], '\n')
+		if end < 0 {
+			break
+		}
+		line := strings.TrimSpace(src[pos : pos+end])
+		if line != "" && line[0] != ';' {
+			break
+		}
+		pos += end + 1
+	}
+	return src[:pos], src[pos:]
+}
+
+// splitBottom splits src before the comments and blank lines at the end.
+func splitBottom(src string) (rest, bottom string) {
+	pos := len(src)
+	for pos > 0 {
+		begin := strings.LastIndexByte(src[:pos-1], '\n') + 1
+		line := strings.TrimSpace(src[begin:pos])
+		if line != "" && line[0] != ';' {
+			break
+		}
+		pos = begin
+	}
+	return src[:pos], src[pos:]
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runConvert(fs *flag.FlagSet, args []string) error {
+	to := fs.String("to", "binary", "output format : text, json or binary")
+	out := fs.String("o", "", "output file, or directory when several pictures are written as text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *out == "" {
+		fs.Usage()
+		return fmt.Errorf("no input files or no output given")
+	}
+
+	var pictures []*picture.Picture
+	for _, fileName := range fs.Args() {
+		pics, err := readPictures(fileName)
+		if err != nil {
+			return err
+		}
+		pictures = append(pictures, pics...)
+	}
+
+	switch *to {
+	case "binary":
+		var buf bytes.Buffer
+		if err := picture.WriteBinary(&buf, pictures); err != nil {
+			return err
+		}
+		return os.WriteFile(*out, buf.Bytes(), 0644)
+	case "json":
+		var data []byte
+		var err error
+		if len(pictures) == 1 {
+			data, err = json.MarshalIndent(pictures[0], "", "  ")
+		} else {
+			data, err = json.MarshalIndent(pictures, "", "  ")
+		}
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*out, append(data, '\n'), 0644)
+	case "text":
+		if len(pictures) == 1 {
+			return pictures[0].WriteFile(*out)
+		}
+		if err := os.MkdirAll(*out, 0755); err != nil {
+			return err
+		}
+		for i, p := range pictures {
+			if err := p.WriteFile(filepath.Join(*out, fmt.Sprintf("%d.apt", i+1))); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format : %s", *to)
+	}
+}
+
+// readPictures reads the pictures in a text, JSON (a picture or an array
+// of pictures) or binary file.
+func readPictures(fileName string) ([]*picture.Picture, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var pictures []*picture.Picture
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case apt.IsBinary(data):
+		pictures, err = picture.ReadBinary(bytes.NewReader(data))
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err = json.Unmarshal(data, &pictures)
+		if err == nil && slices.Contains(pictures, nil) {
+			err = fmt.Errorf("null picture")
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var p picture.Picture
+		err = json.Unmarshal(data, &p)
+		pictures = []*picture.Picture{&p}
+	default:
+		var p *picture.Picture
+		p, err = picture.Parse(string(data))
+		pictures = []*picture.Picture{p}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", fileName, err)
+	}
+	return pictures, nil
+}
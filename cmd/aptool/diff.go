@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+var channelNames = []string{"R", "G", "B"}
+
+func runDiff(fs *flag.FlagSet, args []string) error {
+	stat := fs.Bool("stat", false, "only print how many edits of each kind there are")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two files")
+	}
+
+	a, err := picture.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := picture.Load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	edits := a.Diff(b)
+	if len(edits) == 0 {
+		fmt.Println("the pictures are the same")
+		return nil
+	}
+	if *stat {
+		counts := make(map[apt.EditKind]int)
+		for _, e := range edits {
+			counts[e.Kind]++
+		}
+		for kind := apt.EditConstant; kind <= apt.EditReplace; kind++ {
+			if counts[kind] > 0 {
+				fmt.Printf("%-9s %d\n", kind, counts[kind])
+			}
+		}
+		return nil
+	}
+	for _, e := range edits {
+		fmt.Printf("%s : %s\n", pathString(e.Path), e)
+	}
+	return nil
+}
+
+// pathString returns a path in a picture as the channel name followed by
+// the child indexes, for example "G.0.1".
+func pathString(path []int) string {
+	parts := []string{channelNames[path[0]]}
+	for _, i := range path[1:] {
+		parts = append(parts, strconv.Itoa(i))
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runGoGen(fs *flag.FlagSet, args []string) error {
+	pkg := fs.String("pkg", "main", "package of the generated file")
+	funcName := fs.String("func", "Picture", "name of the generated function")
+	out := fs.String("o", "", "output file (default standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one file")
+	}
+
+	p, err := picture.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	src, err := p.GenerateGo(*pkg, *funcName)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0644)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// goldenSize is the width and height of the golden pictures.
+const goldenSize = 64
+
+// goldenManifest is the file, in the golden directory, with the SHA-256
+// of the pixels of each picture, in the format of sha256sum.
+const goldenManifest = "pixels.sha256"
+
+func runGolden(fs *flag.FlagSet, args []string) error {
+	update := fs.Bool("update", false, "write the hashes of the Go renderer to the manifest instead of checking them")
+	checkJS := fs.Bool("js", false, "also check the JavaScript modules made by aptool js, with Node.js")
+	wasm := fs.String("wasm", "", "also check this build of cmd/aptwasm, with Node.js")
+	node := fs.String("node", "node", "Node.js executable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir := filepath.Join("testdata", "golden")
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one directory")
+	} else if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.apt"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .apt files in %s", dir)
+	}
+	slices.Sort(files)
+
+	pictures, hashes, err := goHashes(files)
+	if err != nil {
+		return err
+	}
+
+	if *update {
+		var sb strings.Builder
+		for _, fileName := range files {
+			fmt.Fprintf(&sb, "%s  %s\n", hashes[filepath.Base(fileName)], filepath.Base(fileName))
+		}
+		if err = os.WriteFile(filepath.Join(dir, goldenManifest), []byte(sb.String()), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("%s : wrote the hashes of %d pictures\n", dir, len(files))
+		return nil
+	}
+
+	want, err := readHashes(filepath.Join(dir, goldenManifest))
+	if err != nil {
+		return err
+	}
+	mismatches := compareHashes("go", want, hashes)
+	if *checkJS {
+		got, err := jsHashes(*node, files, pictures)
+		if err != nil {
+			return err
+		}
+		mismatches += compareHashes("js", want, got)
+	}
+	if *wasm != "" {
+		got, err := wasmHashes(*node, *wasm, files)
+		if err != nil {
+			return err
+		}
+		mismatches += compareHashes("wasm", want, got)
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d pictures differ from the golden pixels", mismatches)
+	}
+	fmt.Printf("%s : %d pictures ok\n", dir, len(files))
+	return nil
+}
+
+// goHashes loads the pictures, and renders them with the Go renderer.
+func goHashes(files []string) ([]*picture.Picture, map[string]string, error) {
+	pictures := make([]*picture.Picture, len(files))
+	hashes := make(map[string]string)
+	for i, fileName := range files {
+		var err error
+		if pictures[i], err = picture.Load(fileName); err != nil {
+			return nil, nil, err
+		}
+		hashes[filepath.Base(fileName)] = fmt.Sprintf("%x", sha256.Sum256(pictures[i].Pixels(goldenSize, goldenSize)))
+	}
+	return pictures, hashes, nil
+}
+
+// compareHashes prints the pictures whose hashes in got differ from want,
+// and returns how many there are.
+func compareHashes(renderer string, want, got map[string]string) int {
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	mismatches := 0
+	for _, name := range names {
+		switch {
+		case want[name] == "":
+			fmt.Printf("%s : not in the manifest\n", name)
+		case got[name] == "":
+			fmt.Printf("%s : missing (%s)\n", name, renderer)
+		case got[name] != want[name]:
+			fmt.Printf("%s : different pixels (%s)\n", name, renderer)
+		default:
+			continue
+		}
+		mismatches++
+	}
+	return mismatches
+}
+
+// readHashes reads hashes in the format of sha256sum, by base file name.
+func readHashes(fileName string) (map[string]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return parseHashes(string(data))
+}
+
+func parseHashes(s string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		hash, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			return nil, fmt.Errorf("invalid hash line : %q", scanner.Text())
+		}
+		hashes[filepath.Base(name)] = hash
+	}
+	return hashes, scanner.Err()
+}
+
+// jsHashes renders the pictures with the modules generated by GenerateJS.
+func jsHashes(node string, files []string, pictures []*picture.Picture) (map[string]string, error) {
+	dir, err := os.MkdirTemp("", "aptool-golden")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var script strings.Builder
+	script.WriteString("import { createHash } from \"node:crypto\";\n")
+	for i, p := range pictures {
+		src, err := p.GenerateJS()
+		if err != nil {
+			return nil, fmt.Errorf("%s : %w", files[i], err)
+		}
+		module := filepath.Join(dir, fmt.Sprintf("picture%d.mjs", i))
+		if err = os.WriteFile(module, []byte(src), 0644); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&script, "{\n\tconst { pixels } = await import(%q);\n", "./"+filepath.Base(module))
+		fmt.Fprintf(&script, "\tconst hash = createHash(\"sha256\").update(pixels(%d, %d)).digest(\"hex\");\n", goldenSize, goldenSize)
+		fmt.Fprintf(&script, "\tconsole.log(hash + \"  \" + %q);\n}\n", filepath.Base(files[i]))
+	}
+	main := filepath.Join(dir, "main.mjs")
+	if err = os.WriteFile(main, []byte(script.String()), 0644); err != nil {
+		return nil, err
+	}
+	return runHashes(exec.Command(node, main))
+}
+
+// wasmHashes renders the pictures with a build of cmd/aptwasm.
+func wasmHashes(node, wasm string, files []string) (map[string]string, error) {
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env GOROOT : %w", err)
+	}
+	wasmExec := filepath.Join(strings.TrimSpace(string(goroot)), "lib", "wasm", "wasm_exec_node.js")
+	args := append([]string{wasmExec, wasm, "-size", fmt.Sprint(goldenSize)}, files...)
+	return runHashes(exec.Command(node, args...))
+}
+
+func runHashes(cmd *exec.Cmd) (map[string]string, error) {
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", cmd.Path, err)
+	}
+	return parseHashes(string(out))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// goldenDir is testdata/golden, seen from this package.
+var goldenDir = filepath.Join("..", "..", "testdata", "golden")
+
+// loadGolden loads the golden pictures, renders them with the Go renderer,
+// and returns them with the hashes of the manifest.
+func loadGolden(t *testing.T) (files []string, pictures []*picture.Picture, got, want map[string]string) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(goldenDir, "*.apt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no .apt files in %s", goldenDir)
+	}
+	slices.Sort(files)
+
+	if pictures, got, err = goHashes(files); err != nil {
+		t.Fatal(err)
+	}
+	if want, err = readHashes(filepath.Join(goldenDir, goldenManifest)); err != nil {
+		t.Fatal(err)
+	}
+	return files, pictures, got, want
+}
+
+// lookNode returns the path of Node.js, and skips the test if it is not installed.
+func lookNode(t *testing.T) string {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	return node
+}
+
+func TestGoldenGo(t *testing.T) {
+	_, _, got, want := loadGolden(t)
+	if mismatches := compareHashes("go", want, got); mismatches > 0 {
+		t.Errorf("%d pictures differ from the golden pixels", mismatches)
+	}
+}
+
+func TestGoldenJS(t *testing.T) {
+	node := lookNode(t)
+	files, pictures, _, want := loadGolden(t)
+	got, err := jsHashes(node, files, pictures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatches := compareHashes("js", want, got); mismatches > 0 {
+		t.Errorf("%d pictures differ from the golden pixels", mismatches)
+	}
+}
+
+func TestGoldenWasm(t *testing.T) {
+	node := lookNode(t)
+	if testing.Short() {
+		t.Skip("building cmd/aptwasm takes a while")
+	}
+	files, _, _, want := loadGolden(t)
+
+	wasm := filepath.Join(t.TempDir(), "aptwasm.wasm")
+	build := exec.Command("go", "build", "-o", wasm, "../aptwasm")
+	build.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building cmd/aptwasm : %v\n%s", err, out)
+	}
+	got, err := wasmHashes(node, wasm, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatches := compareHashes("wasm", want, got); mismatches > 0 {
+		t.Errorf("%d pictures differ from the golden pixels", mismatches)
+	}
+}
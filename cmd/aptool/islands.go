@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/evolution"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runIslands(fs *flag.FlagSet, args []string) error {
+	settings := evolution.DefaultSettings()
+	target := fs.String("target", "", "image (png or jpeg) that the pictures should look like")
+	islands := fs.Int("islands", 4, "number of islands")
+	fs.IntVar(&settings.Size, "size", settings.Size, "number of pictures on each island")
+	generations := fs.Int("generations", 100, "number of generations")
+	interval := fs.Int("interval", 10, "generations between migrations")
+	migrants := fs.Int("migrants", 2, "number of pictures sent from each island at a migration")
+	topology := fs.String("topology", evolution.TopologyRing.String(), "migration topology : ring or full")
+	seed := fs.Int64("seed", 1, "seed of the first island, the following islands use seed+1, seed+2...")
+	weights := fs.String("weights", "", "comma separated operator weight files, assigned to the islands in turn")
+	crossover := fs.String("crossover", settings.Crossover.String(), "crossover mode : subtree, channel, uniform or sizefair")
+	parsimony := fs.Float64("parsimony", 0, "score lost per node in a picture")
+	resolution := fs.Int("resolution", 32, "size of the renders compared to the target")
+	out := fs.String("out", ".", "directory where the best pictures are written")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" {
+		fs.Usage()
+		return fmt.Errorf("no target image given")
+	}
+	if *islands < 1 || settings.Size < 1 {
+		return fmt.Errorf("-islands and -size must be at least 1")
+	}
+
+	targetImage, err := loadImage(*target)
+	if err != nil {
+		return err
+	}
+	settings.Fitness = picture.WithParsimony(picture.TargetFitness(targetImage, *resolution), *parsimony)
+	if settings.Crossover, err = picture.ParseCrossoverMode(*crossover); err != nil {
+		return err
+	}
+
+	config := evolution.IslandConfig{
+		Settings:          settings,
+		Generations:       *generations,
+		MigrationInterval: *interval,
+		Migrants:          *migrants,
+	}
+	if config.Topology, err = evolution.ParseTopology(*topology); err != nil {
+		return err
+	}
+
+	var weightFiles []map[string]float64
+	if *weights != "" {
+		for _, fileName := range strings.Split(*weights, ",") {
+			w, err := readWeights(fileName)
+			if err != nil {
+				return err
+			}
+			weightFiles = append(weightFiles, w)
+		}
+	}
+	for i := 0; i < *islands; i++ {
+		island := evolution.Island{Seed: *seed + int64(i)}
+		if len(weightFiles) > 0 {
+			island.Weights = weightFiles[i%len(weightFiles)]
+		}
+		config.Islands = append(config.Islands, island)
+	}
+
+	config.Progress = func(generation int, populations []*evolution.Population) {
+		fmt.Printf("generation %d :", generation)
+		for _, p := range populations {
+			fmt.Printf(" %.5f", p.BestScore())
+		}
+		fmt.Println()
+	}
+
+	populations := evolution.RunIslands(config)
+
+	var best *picture.Picture
+	bestScore := 0.0
+	for i, p := range populations {
+		pic := p.Best(1)[0]
+		header := picture.NewHeader()
+		header.Set("seed", strconv.FormatInt(config.Islands[i].Seed, 10))
+		header.Set("generation", strconv.Itoa(p.Generation))
+		if err = pic.WriteFileWithHeader(filepath.Join(*out, fmt.Sprintf("island-%d.apt", i+1)), header); err != nil {
+			return err
+		}
+		if best == nil || p.BestScore() > bestScore {
+			best, bestScore = pic, p.BestScore()
+		}
+	}
+	return best.WriteFile(filepath.Join(*out, "best.apt"))
+}
+
+func loadImage(fileName string) (image.Image, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", fileName, err)
+	}
+	return img, nil
+}
+
+func readWeights(fileName string) (map[string]float64, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	weights, err := apt.ReadWeights(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", fileName, err)
+	}
+	return weights, nil
+}
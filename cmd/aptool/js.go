@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runJS(fs *flag.FlagSet, args []string) error {
+	out := fs.String("o", "", "output file (default standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one file")
+	}
+
+	p, err := picture.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	src, err := p.GenerateJS()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = fmt.Print(src)
+		return err
+	}
+	return os.WriteFile(*out, []byte(src), 0644)
+}
@@ -0,0 +1,71 @@
+// Command aptool works with .apt files without opening the GUI.
+//
+// Usage:
+//
+//	aptool <command> [flags] [arguments]
+//
+// Run "aptool help" for a list of the commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"verify", "verify [-width w] [-height h] file.apt...", "render files with both the naive and the cached renderer and compare the results", runVerify},
+		{"convert", "convert [-to text|json|binary] -o output file...", "convert pictures between the text, JSON and binary formats", runConvert},
+		{"gogen", "gogen [-pkg name] [-func name] [-o file.go] file.apt", "generate a standalone Go function that computes a picture", runGoGen},
+		{"shader", "shader [-lang glsl|wgsl] [-o file] [-verify] file.apt", "export a picture as a GLSL or WGSL fragment shader", runShader},
+		{"js", "js [-o file.js] file.apt", "export a picture as a JavaScript module that draws it on a canvas", runJS},
+		{"diff", "diff [-stat] a.apt b.apt", "print the edits that change one picture into another", runDiff},
+		{"golden", "golden [-update] [-js] [-wasm aptwasm.wasm] [dir]", "check that the Go, JavaScript and wasm renderers give the golden pixels", runGolden},
+		{"islands", "islands -target image [flags]", "evolve pictures towards a target image on islands that exchange their best pictures", runIslands},
+		{"pareto", "pareto -target image [flags]", "evolve pictures on similarity to a target and simplicity, and export the Pareto front", runPareto},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+		printUsage()
+		return
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+			fs.Usage = func() {
+				fmt.Fprintf(os.Stderr, "usage : aptool %s\n", c.usage)
+				fs.PrintDefaults()
+			}
+			if err := c.run(fs, os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "aptool %s : %s\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "aptool : unknown command %s\n", os.Args[1])
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage : aptool <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr, "\nThe commands are :")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/hultan/evolvingImage/evolution"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runPareto(fs *flag.FlagSet, args []string) error {
+	settings := evolution.DefaultSettings()
+	target := fs.String("target", "", "image (png or jpeg) that the pictures should look like")
+	fs.IntVar(&settings.Size, "size", settings.Size, "number of pictures in the population")
+	generations := fs.Int("generations", 100, "number of generations")
+	seed := fs.Int64("seed", 1, "seed of the random generator")
+	weights := fs.String("weights", "", "file with operator weights")
+	crossover := fs.String("crossover", settings.Crossover.String(), "crossover mode : subtree, channel, uniform or sizefair")
+	speed := fs.Bool("speed", false, "also prefer pictures that render fast (makes the run irreproducible)")
+	resolution := fs.Int("resolution", 32, "size of the renders compared to the target")
+	thumbnail := fs.Int("thumbnail", 128, "size of the exported thumbnails")
+	out := fs.String("out", "front", "directory where the Pareto front is exported")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" {
+		fs.Usage()
+		return fmt.Errorf("no target image given")
+	}
+	if settings.Size < 1 {
+		return fmt.Errorf("-size must be at least 1")
+	}
+
+	targetImage, err := loadImage(*target)
+	if err != nil {
+		return err
+	}
+	if settings.Crossover, err = picture.ParseCrossoverMode(*crossover); err != nil {
+		return err
+	}
+
+	var w map[string]float64
+	if *weights != "" {
+		if w, err = readWeights(*weights); err != nil {
+			return err
+		}
+	}
+
+	objectives := []evolution.Objective{
+		{Name: "similarity", Score: picture.TargetFitness(targetImage, *resolution)},
+		evolution.Simplicity(),
+	}
+	if *speed {
+		objectives = append(objectives, evolution.RenderSpeed(*resolution))
+	}
+
+	population := evolution.NewParetoPopulation(settings, objectives, *seed, w)
+	for population.Generation < *generations {
+		population.Step()
+		if population.Generation%10 == 0 {
+			fmt.Printf("generation %d : %d pictures in the front\n", population.Generation, len(population.Front()))
+		}
+	}
+	return population.ExportFront(*out, *thumbnail)
+}
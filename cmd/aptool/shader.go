@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runShader(fs *flag.FlagSet, args []string) error {
+	lang := fs.String("lang", apt.GLSL.String(), "shading language : glsl or wgsl")
+	out := fs.String("o", "", "output file (default standard output)")
+	verify := fs.Bool("verify", false, "compare the shader program run with 32-bit floats with the interpreter, instead of writing it (the shader text is not compiled)")
+	size := fs.Int("size", 100, "size of the grid used by -verify")
+	tolerance := fs.Float64("tolerance", 1e-3, "largest difference accepted by -verify")
+	maxDiff := fs.Float64("maxdiff", 1, "percentage of the components that -verify accepts to differ")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one file")
+	}
+
+	language, err := apt.ParseShaderLanguage(*lang)
+	if err != nil {
+		return err
+	}
+	p, err := picture.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *verify {
+		if *size < 1 {
+			return fmt.Errorf("-size must be at least 1")
+		}
+		mismatches, err := apt.CompareFloat32(p.R, p.G, p.B, *size, *tolerance)
+		if err != nil {
+			return err
+		}
+		total := 3 * *size * *size
+		percentage := 100 * float64(mismatches) / float64(total)
+		fmt.Printf("%s : %d of %d components (%.2f%%) differ by more than %g\n",
+			fs.Arg(0), mismatches, total, percentage, *tolerance)
+		if percentage > *maxDiff {
+			return fmt.Errorf("more than %g%% of the components differ", *maxDiff)
+		}
+		return nil
+	}
+
+	src, err := p.GenerateShader(language)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = fmt.Print(src)
+		return err
+	}
+	return os.WriteFile(*out, []byte(src), 0644)
+}
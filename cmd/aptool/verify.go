@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func runVerify(fs *flag.FlagSet, args []string) error {
+	width := fs.Int("width", 400, "width of the rendered pictures")
+	height := fs.Int("height", 400, "height of the rendered pictures")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	xs := picture.Coordinates(*width)
+	ys := picture.Coordinates(*height)
+	naive := make([]float64, *width**height)
+	cached := make([]float64, *width**height)
+
+	failed := 0
+	for _, fileName := range fs.Args() {
+		p, err := picture.Load(fileName)
+		if err != nil {
+			return err
+		}
+
+		var naiveTime, cachedTime time.Duration
+		mismatches := 0
+		for _, channel := range []apt.Node{p.R, p.G, p.B} {
+			start := time.Now()
+			apt.EvaluateGridNaive(channel, xs, ys, naive)
+			naiveTime += time.Since(start)
+
+			start = time.Now()
+			apt.EvaluateGrid(channel, xs, ys, cached)
+			cachedTime += time.Since(start)
+
+			for i := range naive {
+				if !sameValue(naive[i], cached[i]) {
+					mismatches++
+				}
+			}
+		}
+
+		status := "ok"
+		if mismatches > 0 {
+			status = fmt.Sprintf("FAIL (%d values differ)", mismatches)
+			failed++
+		}
+		fmt.Printf("%s : %s, naive %v, cached %v\n", fileName, status, naiveTime, cachedTime)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d files rendered differently", failed)
+	}
+	return nil
+}
+
+// sameValue compares two values bit by bit, treating all NaNs as equal.
+func sameValue(a, b float64) bool {
+	if math.IsNaN(a) && math.IsNaN(b) {
+		return true
+	}
+	return math.Float64bits(a) == math.Float64bits(b)
+}
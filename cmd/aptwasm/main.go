@@ -0,0 +1,72 @@
+//go:build js && wasm
+
+// Command aptwasm renders .apt files in the browser. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o aptwasm.wasm ./cmd/aptwasm
+//
+// and serve it together with index.html and wasm_exec.js (from
+// $(go env GOROOT)/lib/wasm). It defines the JavaScript function
+//
+//	renderAPT(source, canvas)
+//
+// that draws the picture in source (the contents of an .apt file) on the
+// canvas, and returns an error message, or null.
+//
+// Run with arguments under Node.js, it prints the SHA-256 of the pixels of
+// each file instead, like aptool golden does:
+//
+//	node wasm_exec_node.js aptwasm.wasm [-size n] file.apt...
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"syscall/js"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		hashFiles()
+		return
+	}
+
+	js.Global().Set("renderAPT", js.FuncOf(renderAPT))
+	select {}
+}
+
+// renderAPT is renderAPT(source, canvas) in JavaScript.
+func renderAPT(_ js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		return "renderAPT expects a source and a canvas"
+	}
+	p, err := picture.Parse(args[0].String())
+	if err != nil {
+		return err.Error()
+	}
+
+	canvas := args[1]
+	width, height := canvas.Get("width").Int(), canvas.Get("height").Int()
+	pixels := p.Pixels(width, height)
+	data := js.Global().Get("Uint8ClampedArray").New(len(pixels))
+	js.CopyBytesToJS(data, pixels)
+	image := js.Global().Get("ImageData").New(data, width, height)
+	canvas.Call("getContext", "2d").Call("putImageData", image, 0, 0)
+	return nil
+}
+
+func hashFiles() {
+	size := flag.Int("size", 64, "width and height of the rendered pictures")
+	flag.Parse()
+	for _, fileName := range flag.Args() {
+		p, err := picture.Load(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%x  %s\n", sha256.Sum256(p.Pixels(*size, *size)), fileName)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+// defaultConfigFile is the config file that is loaded when no -config is
+// given, from the working directory or else from the user config directory.
+const defaultConfigFile = "evolving.conf"
+
+// findConfig returns the default config file, or "" if there is none.
+func findConfig() string {
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		fileName := filepath.Join(dir, "evolvingImage", defaultConfigFile)
+		if _, err := os.Stat(fileName); err == nil {
+			return fileName
+		}
+	}
+	return ""
+}
+
+// commandLine holds the names of the flags given on the command line,
+// which config files and sessions don't override.
+var commandLine = make(map[string]bool)
+
+// loadConfig sets the flags named in a config file, which has one
+// "flag = value" per line. Empty lines and lines that start with # are
+// skipped. Operator weights are set with "weight.name = value", where
+// value is a weight or one of on/off, like in the -operators file, and
+// keys are bound with "key.action = name" (see keyActions). Flags given
+// on the command line are left as they are.
+func loadConfig(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	weights, bindings := false, false
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s : line %d : expected flag = value", fileName, lineNumber)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if operator, ok := strings.CutPrefix(name, "weight."); ok {
+			if err = setWeight(operator, value); err != nil {
+				return fmt.Errorf("%s : line %d : %w", fileName, lineNumber, err)
+			}
+			weights = true
+			continue
+		}
+		if action, ok := strings.CutPrefix(name, "key."); ok {
+			if err = setKey(action, value); err != nil {
+				return fmt.Errorf("%s : line %d : %w", fileName, lineNumber, err)
+			}
+			bindings = true
+			continue
+		}
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s : line %d : unknown setting %s", fileName, lineNumber, name)
+		}
+		if commandLine[name] {
+			continue
+		}
+		if err = flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s : line %d : %w", fileName, lineNumber, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if weights {
+		if err = apt.CheckWeights(nil); err != nil {
+			return fmt.Errorf("%s : %w", fileName, err)
+		}
+	}
+	if bindings {
+		if err = checkKeys(); err != nil {
+			return fmt.Errorf("%s : %w", fileName, err)
+		}
+	}
+	return nil
+}
+
+// setWeight sets the weight of an operator to a number, or turns it on or off.
+func setWeight(name, value string) error {
+	switch value {
+	case "on":
+		return apt.SetEnabled(name, true)
+	case "off":
+		return apt.SetEnabled(name, false)
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	return apt.SetWeight(name, weight)
+}
+
+// int32Flag is a flag.Value for the int32 settings of the layout.
+type int32Flag struct {
+	p *int32
+}
+
+func (f int32Flag) String() string {
+	if f.p == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*f.p))
+}
+
+func (f int32Flag) Set(s string) error {
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return err
+	}
+	*f.p = int32(v)
+	return nil
+}
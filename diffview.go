@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+const diffThumbnailSize = 120
+
+var (
+	diffRemoved  = rl.Red
+	diffInserted = rl.Green
+	diffChanged  = rl.Orange
+)
+
+// diffView is a panel in zoom mode, that shows what changed between the
+// parent of the picture and the picture (see apt.Diff). Both trees are
+// shown as outlines next to each other, with the removed nodes in red,
+// the inserted nodes in green, and the changed operators and constants
+// in orange.
+type diffView struct {
+	parent  *picture.Picture // nil if the parent is not known
+	picture *picture.Picture
+	edits   []apt.Edit
+	colors  map[apt.Node]rl.Color
+
+	columns    [2][]outlineLine // of the parent and the picture
+	thumbnails [2]rl.Texture2D
+	scroll     int // first line shown
+}
+
+var diffPanel *diffView
+
+// openDiffView compares the zoomed in picture with its parent.
+func openDiffView() {
+	d := &diffView{
+		parent:  parentOf[state.zoomTree],
+		picture: state.zoomTree,
+		colors:  make(map[apt.Node]rl.Color),
+	}
+	for i, p := range []*picture.Picture{d.parent, d.picture} {
+		if p == nil {
+			continue
+		}
+		d.columns[i] = outlineLines(p, nil)
+		d.thumbnails[i] = rl.LoadTextureFromImage(newImage(p, diffThumbnailSize, diffThumbnailSize, currentRenderSettings()))
+	}
+	if d.parent != nil {
+		d.edits = d.parent.Diff(d.picture)
+		for _, e := range d.edits {
+			d.mark(e)
+		}
+	}
+	diffPanel = d
+}
+
+func closeDiffView() {
+	for _, thumbnail := range diffPanel.thumbnails {
+		if thumbnail.ID != 0 {
+			rl.UnloadTexture(thumbnail)
+		}
+	}
+	diffPanel = nil
+}
+
+// mark colors the nodes changed by e.
+func (d *diffView) mark(e apt.Edit) {
+	switch e.Kind {
+	case apt.EditConstant, apt.EditOperator:
+		d.colors[e.A] = diffChanged
+		d.colors[e.B] = diffChanged
+	case apt.EditInsert:
+		if e.A == nil {
+			d.markTree(e.B, -1, diffInserted)
+		} else {
+			d.markTree(e.B, e.Child, diffInserted)
+		}
+	case apt.EditRemove:
+		if e.B == nil {
+			d.markTree(e.A, -1, diffRemoved)
+		} else {
+			d.markTree(e.A, e.Child, diffRemoved)
+		}
+	case apt.EditReplace:
+		d.markTree(e.A, -1, diffRemoved)
+		d.markTree(e.B, -1, diffInserted)
+	}
+}
+
+// markTree colors node and its children, except the child with index keep.
+func (d *diffView) markTree(node apt.Node, keep int, color rl.Color) {
+	d.colors[node] = color
+	for i, child := range node.GetChildren() {
+		if i != keep {
+			d.markTree(child, -1, color)
+		}
+	}
+}
+
+// changed returns true if line i of either outline shows a difference.
+func (d *diffView) changed(i int) bool {
+	for _, lines := range d.columns {
+		if i >= 0 && i < len(lines) {
+			if _, ok := d.colors[lines[i].node]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// next scrolls to the next (or previous, for a negative step) difference.
+func (d *diffView) next(step int) {
+	for i := d.scroll + step; i >= 0 && i < d.length(); i += step {
+		if d.changed(i) && !d.changed(i-1) {
+			d.scroll = i
+			return
+		}
+	}
+}
+
+// length returns the number of lines in the longest outline.
+func (d *diffView) length() int {
+	return max(len(d.columns[0]), len(d.columns[1]))
+}
+
+// panel returns the rectangle of the diff panel, on the right side of the picture.
+func (d *diffView) panel() rl.Rectangle {
+	x := float32(screenWidth) * 0.45
+	return rl.Rectangle{X: x, Y: 0, Width: float32(screenWidth) - x, Height: float32(screenHeight) * 0.9}
+}
+
+// outlinePosition returns the top of the outlines.
+func (d *diffView) outlinePosition() int32 {
+	return int32(d.panel().Y) + diffThumbnailSize + 30
+}
+
+// visibleLines returns how many lines of the outlines fit in the panel,
+// leaving room for the summary and the keys.
+func (d *diffView) visibleLines() int {
+	bottom := int32(d.panel().Y+d.panel().Height) - 2*textLineHeight - 10
+	return max(int((bottom-d.outlinePosition())/outlineLineHeight), 1)
+}
+
+func (d *diffView) update() {
+	if rl.IsKeyPressed(keys.diff) {
+		closeDiffView()
+		return
+	}
+
+	visible := d.visibleLines()
+	switch {
+	case isKeyPressed(rl.KeyUp):
+		d.scroll--
+	case isKeyPressed(rl.KeyDown):
+		d.scroll++
+	case isKeyPressed(rl.KeyPageUp):
+		d.scroll -= visible
+	case isKeyPressed(rl.KeyPageDown):
+		d.scroll += visible
+	case isKeyPressed(rl.KeyTab):
+		if rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift) {
+			d.next(-1)
+		} else {
+			d.next(1)
+		}
+	}
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 {
+		d.scroll -= int(wheel) * 3
+	}
+	d.scroll = max(min(d.scroll, d.length()-visible), 0)
+}
+
+// summary returns how many edits of each kind there are.
+func (d *diffView) summary() string {
+	switch {
+	case d.parent == nil:
+		return "The parent of this picture is not known"
+	case len(d.edits) == 0:
+		return "The picture is the same as its parent"
+	}
+	counts := make(map[apt.EditKind]int)
+	for _, e := range d.edits {
+		counts[e.Kind]++
+	}
+	var parts []string
+	for kind := apt.EditConstant; kind <= apt.EditReplace; kind++ {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return fmt.Sprintf("%d edits : %s", len(d.edits), strings.Join(parts, ", "))
+}
+
+func (d *diffView) draw() {
+	panel := d.panel()
+	rl.DrawRectangleRec(panel, rl.Fade(rl.Black, 0.8))
+
+	width := int32(panel.Width) / 2
+	titles := []string{"Parent", "Picture"}
+	for i, lines := range d.columns {
+		x, y := int32(panel.X)+int32(i)*width+10, int32(panel.Y)+10
+		if d.thumbnails[i].ID != 0 {
+			rl.DrawTexture(d.thumbnails[i], x, y, rl.White)
+		}
+		rl.DrawText(titles[i], x+diffThumbnailSize+10, y, outlineFontSize, rl.White)
+		d.drawOutline(lines, x, width-20)
+	}
+
+	y := int32(panel.Y+panel.Height) - 2*textLineHeight - 4
+	x := int32(panel.X) + 10
+	rl.DrawText(d.summary(), x, y, textFontSize, rl.LightGray)
+	rl.DrawText("Red : removed, green : inserted, orange : changed. Tab : next, "+keyName(keys.diff)+" : close",
+		x, y+textLineHeight, textFontSize, rl.Gray)
+}
+
+// drawOutline draws the visible lines of an outline, in a column at x.
+func (d *diffView) drawOutline(lines []outlineLine, x0, width int32) {
+	y0 := d.outlinePosition()
+	rl.BeginScissorMode(x0, y0, width, int32(d.visibleLines())*outlineLineHeight)
+	defer rl.EndScissorMode()
+
+	for i := d.scroll; i < len(lines) && i < d.scroll+d.visibleLines(); i++ {
+		line := lines[i]
+		x := x0 + int32(line.depth)*outlineIndent
+		y := y0 + int32(i-d.scroll)*outlineLineHeight
+		color := rl.LightGray
+		if line.parent == nil {
+			color = rl.White
+		}
+		if c, ok := d.colors[line.node]; ok {
+			rl.DrawRectangle(x-4, y-2, x0+width-x+4, outlineLineHeight, rl.Fade(c, 0.25))
+			color = c
+		}
+		rl.DrawText(label(line), x, y, outlineFontSize, color)
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+const (
+	outlineFontSize   = 20
+	outlineLineHeight = 24
+	outlineIndent     = 24
+	// outlineMarkerWidth is the width of the [+] and [-] markers, that
+	// collapse and expand the children of a node
+	outlineMarkerWidth = 36
+)
+
+var channelNames = []string{"R", "G", "B"}
+
+// treeEditor edits the trees of a picture (in stateEdit). The trees are
+// shown as outlines, where nodes can be collapsed, replaced, copied and
+// pasted, and the picture is rendered again after each change.
+type treeEditor struct {
+	picture   *picture.Picture
+	original  *picture.Picture
+	index     int // index of the original in pictures, or -1
+	generator *apt.Generator
+
+	collapsed map[apt.Node]bool
+	selected  int // line of the selected node
+	scroll    int // first line shown
+	clipboard apt.Node
+	history   []*picture.Picture // for undo
+
+	typing  bool // true while a new operator or constant is typed
+	input   string
+	message string
+
+	preview  rl.Texture2D
+	version  int // of the picture, to drop previews of older versions
+	previews previewSlot
+}
+
+// previewResult is a picture rendered in the background, for a version
+// of the picture that is being edited.
+type previewResult struct {
+	image   *rl.Image
+	version int
+}
+
+// previewSlot holds the newest preview rendered in the background, so
+// that renders that finish out of order, or faster than the frames, never
+// hide it.
+type previewSlot struct {
+	mu     sync.Mutex
+	result *previewResult
+}
+
+// put stores result, unless a newer version is already stored.
+func (s *previewSlot) put(result previewResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.result == nil || result.version > s.result.version {
+		s.result = &result
+	}
+}
+
+// take returns the stored preview and empties the slot, or returns nil.
+func (s *previewSlot) take() *previewResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := s.result
+	s.result = nil
+	return result
+}
+
+// outlineLine is a line in the outline, that shows a node.
+type outlineLine struct {
+	node   apt.Node
+	parent apt.Node // nil for the root of a channel
+	index  int      // of node among the children of parent, or of the channel
+	depth  int
+}
+
+var editor *treeEditor
+
+func newTreeEditor(p *picture.Picture, index int) *treeEditor {
+	e := &treeEditor{
+		picture:   p.Copy(),
+		original:  p,
+		index:     index,
+		generator: apt.DefaultGenerator(),
+		collapsed: make(map[apt.Node]bool),
+	}
+	e.render()
+	return e
+}
+
+// openEditor starts editing the zoomed in picture.
+func openEditor() {
+	editor = newTreeEditor(state.zoomTree, state.zoomIndex)
+	state.zoom = stateEdit
+}
+
+// closeEditor goes back to zoom mode, with the edited picture. The edited
+// picture also replaces the original in the current generation.
+func closeEditor() {
+	p, index := editor.picture, editor.index
+	index = replacePicture(index, editor.original, p)
+	if editor.preview.ID != 0 {
+		rl.UnloadTexture(editor.preview)
+	}
+	editor = nil
+	zoomIn(p, index)
+}
+
+// replacePicture replaces pictures[index] with p, if it still is the
+// original (the generation can have changed meanwhile), and returns the
+// index of p in pictures, or -1. The original becomes the parent of p.
+func replacePicture(index int, original, p *picture.Picture) int {
+	// The diff view compares p with the picture it was made from
+	parentOf[p] = original
+	if index < 0 || index >= len(pictures) || pictures[index] != original {
+		return -1
+	}
+	pictures[index] = p
+	// The picture is rendered in the background, and a render of the
+	// original that is still running is dropped when it arrives. The
+	// selection is kept, like in relayout.
+	if b := buttons[index]; b != nil {
+		pendingSelection = selection()
+		rl.UnloadTexture(b.Texture)
+		buttons[index] = nil
+	}
+	renderPicture(index)
+	return index
+}
+
+func (e *treeEditor) channels() []*apt.Node {
+	return []*apt.Node{&e.picture.R, &e.picture.G, &e.picture.B}
+}
+
+// lines returns the lines of the outline, skipping the children of
+// collapsed nodes.
+func (e *treeEditor) lines() []outlineLine {
+	return outlineLines(e.picture, e.collapsed)
+}
+
+// outlineLines returns the lines of the outline of p, skipping the
+// children of collapsed nodes.
+func outlineLines(p *picture.Picture, collapsed map[apt.Node]bool) []outlineLine {
+	var lines []outlineLine
+	var walk func(node, parent apt.Node, index, depth int)
+	walk = func(node, parent apt.Node, index, depth int) {
+		lines = append(lines, outlineLine{node, parent, index, depth})
+		if collapsed[node] {
+			return
+		}
+		for i, child := range node.GetChildren() {
+			walk(child, node, i, depth+1)
+		}
+	}
+	for i, root := range []apt.Node{p.R, p.G, p.B} {
+		walk(root, nil, i, 0)
+	}
+	return lines
+}
+
+// previewSize returns the width and height of the preview.
+func previewSize() int32 {
+	return min(screenHeight-200, screenWidth/2-40)
+}
+
+// render renders the picture in the background, see update.
+func (e *treeEditor) render() {
+	e.version++
+	p, version, size := e.picture.Copy(), e.version, previewSize()
+	settings := currentRenderSettings()
+	go func() {
+		e.previews.put(previewResult{newImage(p, size, size, settings), version})
+	}()
+}
+
+// change saves the picture for undo, before it is changed.
+func (e *treeEditor) change() {
+	e.history = append(e.history, e.picture.Copy())
+	e.message = ""
+}
+
+// replace puts node in the place of the node on line.
+func (e *treeEditor) replace(line outlineLine, node apt.Node) {
+	if line.parent == nil {
+		*e.channels()[line.index] = node
+		node.SetParent(nil)
+	} else {
+		line.parent.GetChildren()[line.index] = node
+		node.SetParent(line.parent)
+	}
+	e.render()
+}
+
+// setOperator replaces the operator of the node on line, keeping its children.
+func (e *treeEditor) setOperator(line outlineLine, name string) {
+	e.change()
+	// Unlink the node first, so that Replace doesn't touch the parent
+	line.node.SetParent(nil)
+	node, err := e.generator.Replace(line.node, name)
+	if err != nil {
+		e.history = e.history[:len(e.history)-1]
+		line.node.SetParent(line.parent)
+		e.message = err.Error()
+		return
+	}
+	e.collapsed[node] = e.collapsed[line.node]
+	e.replace(line, node)
+}
+
+// apply applies the typed text to the node on line, which is either a
+// number, that replaces the node with a constant, or an operator name.
+func (e *treeEditor) apply(line outlineLine, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if value, err := strconv.ParseFloat(text, 64); err == nil {
+		e.change()
+		constant := apt.NewConstant()
+		constant.Value = value
+		e.replace(line, constant)
+		return
+	}
+	if _, ok := apt.LookupOperator(text); !ok {
+		e.message = fmt.Sprintf("unknown operator %q", text)
+		return
+	}
+	e.setOperator(line, text)
+}
+
+// cycleOperator replaces the operator of the node on line with the next
+// (or previous, for a negative step) enabled operator with as many
+// children, so that the children of the node are kept.
+func (e *treeEditor) cycleOperator(line outlineLine, step int) {
+	operators := apt.Operators()
+	name, arity := apt.NodeName(line.node), len(line.node.GetChildren())
+	current := slices.IndexFunc(operators, func(spec apt.OperatorSpec) bool { return spec.Name == name })
+	if current < 0 && step < 0 {
+		current = len(operators)
+	}
+	for i := 1; i <= len(operators); i++ {
+		spec := operators[((current+i*step)%len(operators)+len(operators))%len(operators)]
+		if spec.Enabled && spec.Arity == arity && spec.Name != name {
+			e.setOperator(line, spec.Name)
+			return
+		}
+	}
+	e.message = fmt.Sprintf("no other operator with %d children is on", arity)
+}
+
+func (e *treeEditor) undo() {
+	if len(e.history) == 0 {
+		e.message = "nothing to undo"
+		return
+	}
+	e.picture = e.history[len(e.history)-1]
+	e.history = e.history[:len(e.history)-1]
+	e.collapsed = make(map[apt.Node]bool)
+	e.message = ""
+	e.render()
+}
+
+// label returns the text of the node on line.
+func label(line outlineLine) string {
+	text := apt.NodeName(line.node)
+	if c, ok := line.node.(*apt.OperatorConstant); ok {
+		text = c.String()
+	}
+	if line.parent == nil {
+		text = channelNames[line.index] + " : " + text
+	}
+	return text
+}
+
+func isKeyPressed(key int32) bool {
+	return rl.IsKeyPressed(key) || rl.IsKeyPressedRepeat(key)
+}
+
+func (e *treeEditor) update() {
+	if preview := e.previews.take(); preview != nil && preview.version == e.version {
+		if e.preview.ID != 0 {
+			rl.UnloadTexture(e.preview)
+		}
+		e.preview = rl.LoadTextureFromImage(preview.image)
+	}
+
+	lines := e.lines()
+	e.selected = max(min(e.selected, len(lines)-1), 0)
+	line := lines[e.selected]
+
+	if e.typing {
+		for r := rl.GetCharPressed(); r != 0; r = rl.GetCharPressed() {
+			e.input += string(rune(r))
+		}
+		if isKeyPressed(rl.KeyBackspace) && e.input != "" {
+			runes := []rune(e.input)
+			e.input = string(runes[:len(runes)-1])
+		}
+		if rl.IsKeyPressed(rl.KeyEnter) {
+			e.typing = false
+			e.apply(line, e.input)
+		}
+		return
+	}
+
+	x0, y0 := e.outlinePosition()
+	visible := e.visibleLines()
+	switch {
+	case isKeyPressed(rl.KeyUp):
+		e.selected = max(e.selected-1, 0)
+	case isKeyPressed(rl.KeyDown):
+		e.selected = min(e.selected+1, len(lines)-1)
+	case isKeyPressed(rl.KeyPageUp):
+		e.selected = max(e.selected-visible, 0)
+	case isKeyPressed(rl.KeyPageDown):
+		e.selected = min(e.selected+visible, len(lines)-1)
+	case rl.IsKeyPressed(rl.KeyLeft):
+		if len(line.node.GetChildren()) > 0 && !e.collapsed[line.node] {
+			e.collapsed[line.node] = true
+		} else if line.depth > 0 {
+			// Go to the parent
+			for e.selected > 0 && lines[e.selected].depth >= line.depth {
+				e.selected--
+			}
+		}
+	case rl.IsKeyPressed(rl.KeyRight):
+		if e.collapsed[line.node] {
+			delete(e.collapsed, line.node)
+		} else if len(line.node.GetChildren()) > 0 {
+			e.selected++
+		}
+	case rl.IsKeyPressed(rl.KeySpace):
+		if len(line.node.GetChildren()) > 0 {
+			e.collapsed[line.node] = !e.collapsed[line.node]
+		}
+	case rl.IsKeyPressed(rl.KeyEnter):
+		e.typing = true
+		e.input = ""
+		if c, ok := line.node.(*apt.OperatorConstant); ok {
+			e.input = strconv.FormatFloat(c.Value, 'g', -1, 64)
+		}
+	case rl.IsKeyPressed(rl.KeyTab):
+		if rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift) {
+			e.cycleOperator(line, -1)
+		} else {
+			e.cycleOperator(line, 1)
+		}
+	case rl.IsKeyPressed(rl.KeyDelete):
+		// Delete the subtree, leaving a constant
+		e.change()
+		constant := apt.NewConstant()
+		constant.Value = 0
+		e.replace(line, constant)
+	case rl.IsKeyPressed(rl.KeyC):
+		e.clipboard = apt.CopyTree(line.node, nil)
+		e.message = "copied " + apt.NodeName(line.node)
+	case rl.IsKeyPressed(rl.KeyV):
+		if e.clipboard == nil {
+			e.message = "nothing to paste, copy a subtree with C first"
+			break
+		}
+		e.change()
+		e.replace(line, apt.CopyTree(e.clipboard, nil))
+	case rl.IsKeyPressed(rl.KeyZ):
+		e.undo()
+	case rl.IsKeyPressed(keys.save):
+		size := previewSize()
+		savePicture(e.picture, size, size)
+		e.message = "saved"
+	case rl.IsKeyPressed(keys.edit):
+		closeEditor()
+		return
+	case rl.IsMouseButtonPressed(rl.MouseButtonLeft):
+		mouse := rl.GetMousePosition()
+		i := e.scroll + int((int32(mouse.Y)-y0)/outlineLineHeight)
+		if mouse.X < float32(x0) || mouse.Y < float32(y0) || i >= len(lines) || i >= e.scroll+visible {
+			break
+		}
+		e.selected = i
+		markerX := float32(x0 + int32(lines[i].depth)*outlineIndent)
+		if len(lines[i].node.GetChildren()) > 0 && mouse.X >= markerX && mouse.X < markerX+outlineMarkerWidth {
+			e.collapsed[lines[i].node] = !e.collapsed[lines[i].node]
+		}
+	}
+
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 {
+		e.scroll -= int(wheel) * 3
+	} else {
+		// Keep the selected line visible
+		e.scroll = min(e.scroll, e.selected)
+		e.scroll = max(e.scroll, e.selected-visible+1)
+	}
+	e.scroll = max(min(e.scroll, len(e.lines())-visible), 0)
+}
+
+// outlinePosition returns the top left corner of the outline.
+func (e *treeEditor) outlinePosition() (int32, int32) {
+	return previewSize() + 60, 20
+}
+
+// visibleLines returns how many lines of the outline fit on the screen.
+func (e *treeEditor) visibleLines() int {
+	_, y0 := e.outlinePosition()
+	return max(int((screenHeight-200-y0)/outlineLineHeight), 1)
+}
+
+func (e *treeEditor) draw() {
+	if e.preview.ID != 0 {
+		rl.DrawTexture(e.preview, 20, 20, rl.White)
+	}
+
+	x0, y0 := e.outlinePosition()
+	lines := e.lines()
+	for i := e.scroll; i < len(lines) && i < e.scroll+e.visibleLines(); i++ {
+		line := lines[i]
+		x := x0 + int32(line.depth)*outlineIndent
+		y := y0 + int32(i-e.scroll)*outlineLineHeight
+		if i == e.selected {
+			rl.DrawRectangle(x-4, y-2, screenWidth-x-20, outlineLineHeight, rl.DarkGray)
+		}
+		if len(line.node.GetChildren()) > 0 {
+			marker := "[-]"
+			if e.collapsed[line.node] {
+				marker = "[+]"
+			}
+			rl.DrawText(marker, x, y, outlineFontSize, rl.Gray)
+		}
+		color := rl.LightGray
+		if line.parent == nil {
+			color = rl.White
+		}
+		rl.DrawText(label(line), x+outlineMarkerWidth, y, outlineFontSize, color)
+	}
+
+	if e.typing {
+		rl.DrawText("Operator or constant : "+e.input+"_", 25, screenHeight-170, 24, rl.White)
+	} else if e.message != "" {
+		rl.DrawText(e.message, 25, screenHeight-170, 24, rl.Orange)
+	}
+	rl.DrawText(sizeLabel(e.picture), 25, screenHeight-140, 24, rl.LightGray)
+	rl.DrawText("Up/Down : select, Left/Right/Space : fold, Enter : type an operator or constant, Tab : next operator",
+		25, screenHeight-110, 20, rl.LightGray)
+	rl.DrawText(fmt.Sprintf("Del : delete a subtree, C/V : copy/paste a subtree, Z : undo, %s : save, %s : close the editor",
+		keyName(keys.save), keyName(keys.edit)),
+		25, screenHeight-80, 20, rl.LightGray)
+}
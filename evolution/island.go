@@ -0,0 +1,134 @@
+package evolution
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// Topology decides which islands the migrants of an island are sent to.
+type Topology int
+
+const (
+	// TopologyRing sends the migrants of island i to island i+1.
+	TopologyRing Topology = iota
+	// TopologyFullyConnected sends the migrants of every island to all other islands.
+	TopologyFullyConnected
+)
+
+var topologyNames = []string{"ring", "full"}
+
+func (t Topology) String() string {
+	if t < 0 || int(t) >= len(topologyNames) {
+		return fmt.Sprintf("Topology(%d)", int(t))
+	}
+	return topologyNames[t]
+}
+
+// ParseTopology converts a name returned by Topology.String back into a Topology.
+func ParseTopology(s string) (Topology, error) {
+	for i, name := range topologyNames {
+		if name == s {
+			return Topology(i), nil
+		}
+	}
+	return TopologyRing, fmt.Errorf("unknown topology : %s", s)
+}
+
+// Island contains the settings that are specific to one island.
+type Island struct {
+	Seed int64
+	// Weights overrides the operator weights of the registry (see apt.Generator.SetWeight), may be nil.
+	Weights map[string]float64
+}
+
+// IslandConfig contains the settings of an island model run.
+type IslandConfig struct {
+	Settings
+	Islands     []Island
+	Generations int
+	// MigrationInterval is the number of generations between migrations.
+	MigrationInterval int
+	// Migrants is the number of best pictures sent from every island at a migration.
+	Migrants int
+	Topology Topology
+	// Progress is called (if not nil) after every migration interval, from the calling goroutine.
+	Progress func(generation int, islands []*Population)
+}
+
+// RunIslands evolves one population per island concurrently, each in its own
+// goroutine and with its own seed. Every MigrationInterval generations, the
+// islands wait for each other and exchange their best pictures along the
+// topology, replacing the worst pictures of the receiving island. Since
+// migrations happen at these synchronization points, a run is reproducible
+// from its seeds. The final populations are returned.
+func RunIslands(config IslandConfig) []*Population {
+	islands := make([]*Population, len(config.Islands))
+	var wg sync.WaitGroup
+	for i, island := range config.Islands {
+		wg.Add(1)
+		go func(i int, island Island) {
+			defer wg.Done()
+			islands[i] = NewPopulation(config.Settings, island.Seed, island.Weights)
+		}(i, island)
+	}
+	wg.Wait()
+
+	interval := config.MigrationInterval
+	if interval <= 0 {
+		interval = config.Generations
+	}
+
+	for generation := 0; generation < config.Generations; {
+		steps := min(interval, config.Generations-generation)
+		for _, population := range islands {
+			wg.Add(1)
+			go func(population *Population) {
+				defer wg.Done()
+				for i := 0; i < steps; i++ {
+					population.Step()
+				}
+			}(population)
+		}
+		wg.Wait()
+		generation += steps
+
+		if generation < config.Generations {
+			migrate(islands, config.Migrants, config.Topology)
+		}
+		if config.Progress != nil {
+			config.Progress(generation, islands)
+		}
+	}
+	return islands
+}
+
+// migrate sends the best pictures of every island to its neighbours.
+func migrate(islands []*Population, migrants int, topology Topology) {
+	if len(islands) < 2 || migrants <= 0 {
+		return
+	}
+
+	// Pick all migrants before any island is changed
+	emigrants := make([][]*picture.Picture, len(islands))
+	for i, island := range islands {
+		emigrants[i] = island.Best(migrants)
+	}
+
+	for i, island := range islands {
+		var immigrants []*picture.Picture
+		switch topology {
+		case TopologyRing:
+			from := (i - 1 + len(islands)) % len(islands)
+			immigrants = emigrants[from]
+		case TopologyFullyConnected:
+			for from := range islands {
+				if from != i {
+					immigrants = append(immigrants, emigrants[from]...)
+				}
+			}
+		}
+		island.ReplaceWorst(immigrants)
+	}
+}
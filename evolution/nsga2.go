@@ -0,0 +1,318 @@
+package evolution
+
+import (
+	"fmt"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// Objective is one of the scores optimised by a ParetoPopulation.
+type Objective struct {
+	Name string
+	// Score scores a picture, a higher score is better.
+	Score picture.FitnessFunc
+}
+
+// Simplicity returns an objective that prefers pictures with few nodes.
+func Simplicity() Objective {
+	return Objective{
+		Name: "simplicity",
+		Score: func(p *picture.Picture) float64 {
+			return -float64(p.NodeCount())
+		},
+	}
+}
+
+// RenderSpeed returns an objective that prefers pictures that render fast
+// at size x size pixels. The score is minus the render time in seconds, so
+// unlike the other objectives it varies between runs.
+func RenderSpeed(size int) Objective {
+	return Objective{
+		Name: "speed",
+		Score: func(p *picture.Picture) float64 {
+			start := time.Now()
+			p.Evaluate(size, size)
+			return -time.Since(start).Seconds()
+		},
+	}
+}
+
+// ParetoPopulation evolves pictures on several objectives at once, using
+// NSGA-II. Instead of a single best picture it keeps a Pareto front, the
+// pictures that no other picture beats on all objectives.
+type ParetoPopulation struct {
+	Pictures   []*picture.Picture
+	Scores     [][]float64
+	Generation int
+
+	settings   Settings
+	objectives []Objective
+	breeder    *picture.Breeder
+	rank       []int
+	crowding   []float64
+}
+
+// NewParetoPopulation creates and scores a random population. The Fitness
+// of the settings is not used, the pictures are scored by the objectives.
+func NewParetoPopulation(settings Settings, objectives []Objective, seed int64, weights map[string]float64) *ParetoPopulation {
+	generator := apt.NewGenerator(seed)
+	for name, weight := range weights {
+		generator.SetWeight(name, weight)
+	}
+
+	p := &ParetoPopulation{
+		settings:   settings,
+		objectives: objectives,
+		breeder:    picture.NewBreeder(generator, settings.Limits),
+	}
+	p.Pictures = p.breeder.NewPopulation(settings.Size, settings.Init, settings.InitMinDepth, settings.InitMaxDepth)
+	p.Scores = p.score(p.Pictures)
+	p.rank, p.crowding = sortFronts(p.Scores)
+	return p
+}
+
+// Objectives returns the objectives of the population.
+func (p *ParetoPopulation) Objectives() []Objective {
+	return p.objectives
+}
+
+func (p *ParetoPopulation) score(pictures []*picture.Picture) [][]float64 {
+	scores := make([][]float64, len(pictures))
+	for i, pic := range pictures {
+		scores[i] = make([]float64, len(p.objectives))
+		for j, objective := range p.objectives {
+			scores[i][j] = objective.Score(pic)
+		}
+	}
+	return scores
+}
+
+// Step evolves the population one generation. As many children as there are
+// pictures are bred, and the best half of parents and children survive,
+// ranked by Pareto front and then by crowding distance.
+func (p *ParetoPopulation) Step() {
+	r := p.breeder.Generator.Rand()
+	children := make([]*picture.Picture, len(p.Pictures))
+	for i := range children {
+		a := p.tournament()
+		b := p.tournament()
+		children[i] = p.breeder.Cross(a, b, p.settings.Crossover)
+		if p.settings.MutationRate > 0 {
+			for j := r.Intn(p.settings.MutationRate); j > 0; j-- {
+				p.breeder.Mutate(children[i])
+			}
+		}
+	}
+
+	pictures := append(append([]*picture.Picture{}, p.Pictures...), children...)
+	scores := append(append([][]float64{}, p.Scores...), p.score(children)...)
+	rank, crowding := sortFronts(scores)
+
+	indexes := make([]int, len(pictures))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return better(rank, crowding, indexes[i], indexes[j])
+	})
+	indexes = indexes[:len(p.Pictures)]
+
+	// The crowding distances are recalculated, since they depend on which pictures survived
+	p.Pictures = make([]*picture.Picture, len(indexes))
+	p.Scores = make([][]float64, len(indexes))
+	for i, index := range indexes {
+		p.Pictures[i] = pictures[index]
+		p.Scores[i] = scores[index]
+	}
+	p.rank, p.crowding = sortFronts(p.Scores)
+	p.Generation++
+}
+
+// tournament returns the best of TournamentSize random pictures, using the
+// front as the first and the crowding distance as the second criterion.
+func (p *ParetoPopulation) tournament() *picture.Picture {
+	r := p.breeder.Generator.Rand()
+	best := r.Intn(len(p.Pictures))
+	for i := 1; i < p.settings.TournamentSize; i++ {
+		challenger := r.Intn(len(p.Pictures))
+		if better(p.rank, p.crowding, challenger, best) {
+			best = challenger
+		}
+	}
+	return p.Pictures[best]
+}
+
+// Front returns the indexes of the pictures in the Pareto front, sorted by
+// the score of the first objective, the best first. Of several pictures
+// with identical scores, only the first is included.
+func (p *ParetoPopulation) Front() []int {
+	var front []int
+	for i, rank := range p.rank {
+		if rank == 0 && !p.hasScoresOf(front, i) {
+			front = append(front, i)
+		}
+	}
+	sort.SliceStable(front, func(i, j int) bool {
+		return p.Scores[front[i]][0] > p.Scores[front[j]][0]
+	})
+	return front
+}
+
+func (p *ParetoPopulation) hasScoresOf(indexes []int, i int) bool {
+	for _, j := range indexes {
+		if slices.Equal(p.Scores[i], p.Scores[j]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportFront writes the pictures in the Pareto front to dir, as a gallery
+// of front-N.apt files and size x size front-N.png thumbnails, together
+// with a front.csv file listing the scores of each picture.
+func (p *ParetoPopulation) ExportFront(dir string, size int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	csv, err := os.Create(filepath.Join(dir, "front.csv"))
+	if err != nil {
+		return err
+	}
+	defer csv.Close()
+
+	fmt.Fprint(csv, "file")
+	for _, objective := range p.objectives {
+		fmt.Fprintf(csv, ",%s", objective.Name)
+	}
+	fmt.Fprintln(csv)
+
+	for n, i := range p.Front() {
+		name := fmt.Sprintf("front-%d", n+1)
+		if err = p.Pictures[i].WriteFile(filepath.Join(dir, name+".apt")); err != nil {
+			return err
+		}
+		if err = writePNG(filepath.Join(dir, name+".png"), p.Pictures[i], size); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(csv, "%s.apt", name)
+		for _, score := range p.Scores[i] {
+			fmt.Fprintf(csv, ",%g", score)
+		}
+		fmt.Fprintln(csv)
+	}
+	return csv.Close()
+}
+
+func writePNG(fileName string, p *picture.Picture, size int) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = png.Encode(file, p.Image(size, size)); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// better returns true if picture i is preferred to picture j, which is
+// the case if it is in a better front, or in the same front but in a less
+// crowded part of it.
+func better(rank []int, crowding []float64, i, j int) bool {
+	if rank[i] != rank[j] {
+		return rank[i] < rank[j]
+	}
+	return crowding[i] > crowding[j]
+}
+
+// dominates returns true if a is at least as good as b on all objectives,
+// and better on at least one.
+func dominates(a, b []float64) bool {
+	strictly := false
+	for i := range a {
+		if a[i] < b[i] {
+			return false
+		}
+		if a[i] > b[i] {
+			strictly = true
+		}
+	}
+	return strictly
+}
+
+// sortFronts does the fast non-dominated sort of NSGA-II. It returns the
+// front of every score (0 is the Pareto front), and its crowding distance
+// within the front.
+func sortFronts(scores [][]float64) (rank []int, crowding []float64) {
+	rank = make([]int, len(scores))
+	crowding = make([]float64, len(scores))
+	dominated := make([][]int, len(scores))
+	dominatedBy := make([]int, len(scores))
+
+	var front []int
+	for i := range scores {
+		for j := range scores {
+			if dominates(scores[i], scores[j]) {
+				dominated[i] = append(dominated[i], j)
+			} else if dominates(scores[j], scores[i]) {
+				dominatedBy[i]++
+			}
+		}
+		if dominatedBy[i] == 0 {
+			front = append(front, i)
+		}
+	}
+
+	for r := 0; len(front) > 0; r++ {
+		var next []int
+		for _, i := range front {
+			rank[i] = r
+			for _, j := range dominated[i] {
+				dominatedBy[j]--
+				if dominatedBy[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		assignCrowding(scores, front, crowding)
+		front = next
+	}
+	return rank, crowding
+}
+
+// assignCrowding calculates the crowding distance of the scores in a front,
+// the sum over the objectives of the normalized distance between the
+// neighbours on each side. The extremes of every objective get an infinite
+// distance, so that they are always kept.
+func assignCrowding(scores [][]float64, front []int, crowding []float64) {
+	if len(front) == 0 {
+		return
+	}
+	sorted := append([]int{}, front...)
+	for objective := range scores[front[0]] {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return scores[sorted[i]][objective] < scores[sorted[j]][objective]
+		})
+		low := scores[sorted[0]][objective]
+		high := scores[sorted[len(sorted)-1]][objective]
+		crowding[sorted[0]] = math.Inf(1)
+		crowding[sorted[len(sorted)-1]] = math.Inf(1)
+		if high == low {
+			continue
+		}
+		for i := 1; i < len(sorted)-1; i++ {
+			crowding[sorted[i]] += (scores[sorted[i+1]][objective] - scores[sorted[i-1]][objective]) / (high - low)
+		}
+	}
+}
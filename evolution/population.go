@@ -0,0 +1,168 @@
+// Package evolution evolves populations of pictures automatically, scored
+// by a fitness function, for example in long headless runs.
+package evolution
+
+import (
+	"sort"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// Settings contains the parameters used when a population is evolved.
+type Settings struct {
+	// Size is the number of pictures in the population.
+	Size int
+	// Fitness scores the pictures. It is called concurrently when
+	// several populations are evolved at the same time.
+	Fitness picture.FitnessFunc
+	// Crossover is the crossover mode used when breeding.
+	Crossover picture.CrossoverMode
+	// MutationRate bounds the number of mutations of a child, which is
+	// random in [0, MutationRate), like in the GUI. 0 means no mutations.
+	MutationRate int
+	// TournamentSize is the number of pictures competing in every tournament selection.
+	TournamentSize int
+	// Elitism is the number of best pictures copied unchanged into the next generation.
+	Elitism int
+	// Init, InitMinDepth and InitMaxDepth decide how the first generation is created.
+	Init         picture.InitMethod
+	InitMinDepth int
+	InitMaxDepth int
+	// Limits bounds the size of the trees.
+	Limits picture.Limits
+}
+
+// DefaultSettings returns sensible settings, the fitness function has to be set.
+func DefaultSettings() Settings {
+	return Settings{
+		Size:           50,
+		Crossover:      picture.CrossoverSubtree,
+		MutationRate:   4,
+		TournamentSize: 3,
+		Elitism:        2,
+		Init:           picture.InitRamped,
+		InitMinDepth:   2,
+		InitMaxDepth:   6,
+		Limits:         picture.GetLimits(),
+	}
+}
+
+// Population is a set of pictures with their fitness scores. A population has
+// its own random generator, so separate populations can evolve concurrently,
+// but a single population is not safe for concurrent use.
+type Population struct {
+	Pictures   []*picture.Picture
+	Scores     []float64
+	Generation int
+
+	settings Settings
+	breeder  *picture.Breeder
+}
+
+// NewPopulation creates and scores a random population. The seed and the
+// operator weights (which may be nil) are used by the population's generator.
+func NewPopulation(settings Settings, seed int64, weights map[string]float64) *Population {
+	generator := apt.NewGenerator(seed)
+	for name, weight := range weights {
+		generator.SetWeight(name, weight)
+	}
+
+	p := &Population{
+		settings: settings,
+		breeder:  picture.NewBreeder(generator, settings.Limits),
+	}
+	p.Pictures = p.breeder.NewPopulation(settings.Size, settings.Init, settings.InitMinDepth, settings.InitMaxDepth)
+	p.evaluate()
+	return p
+}
+
+// Breeder returns the breeder used by the population.
+func (p *Population) Breeder() *picture.Breeder {
+	return p.breeder
+}
+
+func (p *Population) evaluate() {
+	p.Scores = make([]float64, len(p.Pictures))
+	for i, pic := range p.Pictures {
+		p.Scores[i] = p.settings.Fitness(pic)
+	}
+}
+
+// Step evolves the population one generation, using elitism, tournament
+// selection, crossover and mutation.
+func (p *Population) Step() {
+	next := make([]*picture.Picture, 0, len(p.Pictures))
+	for _, elite := range p.Best(min(p.settings.Elitism, len(p.Pictures))) {
+		next = append(next, elite.Copy())
+	}
+
+	r := p.breeder.Generator.Rand()
+	for len(next) < len(p.Pictures) {
+		a := p.tournament()
+		b := p.tournament()
+		child := p.breeder.Cross(a, b, p.settings.Crossover)
+		if p.settings.MutationRate > 0 {
+			for i := r.Intn(p.settings.MutationRate); i > 0; i-- {
+				p.breeder.Mutate(child)
+			}
+		}
+		next = append(next, child)
+	}
+
+	p.Pictures = next
+	p.evaluate()
+	p.Generation++
+}
+
+// tournament returns the best of TournamentSize random pictures.
+func (p *Population) tournament() *picture.Picture {
+	r := p.breeder.Generator.Rand()
+	best := r.Intn(len(p.Pictures))
+	for i := 1; i < p.settings.TournamentSize; i++ {
+		challenger := r.Intn(len(p.Pictures))
+		if p.Scores[challenger] > p.Scores[best] {
+			best = challenger
+		}
+	}
+	return p.Pictures[best]
+}
+
+// ranking returns the indexes of the pictures, from the best to the worst.
+func (p *Population) ranking() []int {
+	indexes := make([]int, len(p.Pictures))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return p.Scores[indexes[i]] > p.Scores[indexes[j]]
+	})
+	return indexes
+}
+
+// Best returns the n best pictures, the best first.
+func (p *Population) Best(n int) []*picture.Picture {
+	best := make([]*picture.Picture, 0, n)
+	for _, i := range p.ranking()[:min(n, len(p.Pictures))] {
+		best = append(best, p.Pictures[i])
+	}
+	return best
+}
+
+// BestScore returns the score of the best picture.
+func (p *Population) BestScore() float64 {
+	return p.Scores[p.ranking()[0]]
+}
+
+// ReplaceWorst replaces the worst pictures with (copies of) the immigrants.
+func (p *Population) ReplaceWorst(immigrants []*picture.Picture) {
+	ranking := p.ranking()
+	for i, immigrant := range immigrants {
+		if i >= len(ranking) {
+			break
+		}
+		worst := ranking[len(ranking)-1-i]
+		p.Pictures[worst] = immigrant.Copy()
+		p.Scores[worst] = p.settings.Fitness(p.Pictures[worst])
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/novelty"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// evolverConfig contains the settings used when a new generation is bred.
+type evolverConfig struct {
+	mutationRate int
+	crossover    picture.CrossoverMode
+	limits       picture.Limits
+	complexity   picture.Complexity
+	colors       picture.ColorModel
+	init         picture.InitMethod
+	initMinDepth int
+	initMaxDepth int
+	simplify     bool
+	dedupe       bool
+	phenotype    bool
+	novelty      bool
+	candidates   int
+	seed         int64
+}
+
+var config = evolverConfig{
+	mutationRate: 10,
+	crossover:    picture.CrossoverSubtree,
+	limits:       picture.GetLimits(),
+	complexity:   picture.GetComplexity(),
+	init:         picture.InitRandom,
+	initMinDepth: 2,
+	initMaxDepth: 6,
+	dedupe:       true,
+	candidates:   4,
+	seed:         time.Now().UnixNano(),
+}
+
+// generation counts the populations shown, including the first one.
+var generation int
+
+// breeder breeds the current generation (see nextGeneration).
+var breeder *picture.Breeder
+
+// parentOf maps the pictures of the current generation to the parent that
+// they were bred from, which is the first parent of the crossover, for the
+// diff view.
+var parentOf = make(map[*picture.Picture]*picture.Picture)
+
+// archive remembers what has been seen so far in novelty search mode.
+var archive = novelty.NewArchive(10, 1000)
+
+// nextGeneration advances the generation counter, and seeds a new breeder
+// with the seed plus the generation. This way a resumed session continues
+// exactly like the original session would have.
+func nextGeneration() {
+	generation++
+	parentOf = make(map[*picture.Picture]*picture.Picture)
+	breeder = newBreeder()
+}
+
+// newBreeder returns a breeder for the current generation.
+func newBreeder() *picture.Breeder {
+	return picture.NewBreeder(apt.NewGenerator(config.seed+int64(generation)), config.limits)
+}
+
+func evolve(survivors []*picture.Picture) []*picture.Picture {
+	newPics := make([]*picture.Picture, numPics)
+	i := 0
+	for i < len(survivors) && i < len(newPics) {
+		a := survivors[i]
+		b := survivors[breeder.Generator.Rand().Intn(len(survivors))]
+		newPics[i] = breeder.Cross(a, b, config.crossover)
+		parentOf[newPics[i]] = a
+		i++
+	}
+
+	for i < len(newPics) {
+		a := survivors[breeder.Generator.Rand().Intn(len(survivors))]
+		b := survivors[breeder.Generator.Rand().Intn(len(survivors))]
+		newPics[i] = breeder.Cross(a, b, config.crossover)
+		parentOf[newPics[i]] = a
+		i++
+	}
+
+	for _, pic := range newPics {
+		mutate(pic)
+	}
+
+	if config.dedupe {
+		options := picture.DefaultDedupeOptions
+		options.Phenotype = config.phenotype
+		picture.Deduplicate(newPics, options, func() *picture.Picture {
+			pic := breed(survivors)
+			// Make sure that the replacement differs from its parent
+			breeder.Mutate(pic)
+			return pic
+		})
+	}
+
+	return newPics
+}
+
+// evolveNovel fills a new generation with the most novel of a larger
+// set of candidates bred from the parents (see novelty.Archive).
+func evolveNovel(parents []*picture.Picture) []*picture.Picture {
+	candidates := make([]*picture.Picture, int(numPics)*max(config.candidates, 1))
+	for i := range candidates {
+		candidates[i] = breed(parents)
+	}
+	return archive.Select(candidates, int(numPics))
+}
+
+// breed creates a mutated child of two random parents.
+func breed(parents []*picture.Picture) *picture.Picture {
+	a := parents[breeder.Generator.Rand().Intn(len(parents))]
+	b := parents[breeder.Generator.Rand().Intn(len(parents))]
+	pic := breeder.Cross(a, b, config.crossover)
+	parentOf[pic] = a
+	mutate(pic)
+	return pic
+}
+
+// mutate mutates a picture a random number of times, up to the mutation rate.
+func mutate(pic *picture.Picture) {
+	r := breeder.Generator.Rand().Intn(config.mutationRate)
+	for i := 0; i < r; i++ {
+		breeder.Mutate(pic)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// keys are the keys of the actions that can be bound to other keys in the
+// config file, with "key.action = name".
+var keys = struct {
+	edit, text, diff, settings            int32
+	save, newImages, saveSession, novelty int32
+}{
+	edit:        rl.KeyE,
+	text:        rl.KeyF3,
+	diff:        rl.KeyD,
+	settings:    rl.KeyF4,
+	save:        rl.KeyS,
+	newImages:   rl.KeyF5,
+	saveSession: rl.KeyF2,
+	novelty:     rl.KeyN,
+}
+
+// keyActions are the names of the actions in the config file.
+var keyActions = map[string]*int32{
+	"edit":        &keys.edit,
+	"text":        &keys.text,
+	"diff":        &keys.diff,
+	"settings":    &keys.settings,
+	"save":        &keys.save,
+	"new":         &keys.newImages,
+	"savesession": &keys.saveSession,
+	"novelty":     &keys.novelty,
+}
+
+// keyNames are the keys that actions can be bound to : the letters, the
+// digits, F1 to F12, and a few others, except for reservedKeys. Escape
+// closes the window, so it can't be bound.
+var keyNames = func() map[string]int32 {
+	names := map[string]int32{
+		"Space":  rl.KeySpace,
+		"Insert": rl.KeyInsert,
+		"Home":   rl.KeyHome,
+		"End":    rl.KeyEnd,
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		names[string(c)] = rl.KeyA + c - 'A'
+	}
+	for c := '0'; c <= '9'; c++ {
+		names[string(c)] = rl.KeyZero + c - '0'
+	}
+	for i := int32(1); i <= 12; i++ {
+		names[fmt.Sprintf("F%d", i)] = rl.KeyF1 + i - 1
+	}
+	return names
+}()
+
+// reservedKeys are the keys that the editor and the text view use, which
+// can't be bound to actions.
+var reservedKeys = map[int32]string{
+	rl.KeySpace: "collapse in the editor",
+	rl.KeyC:     "copy in the editor",
+	rl.KeyV:     "paste in the editor",
+	rl.KeyZ:     "undo in the editor",
+	rl.KeyHome:  "start of line in the text view",
+	rl.KeyEnd:   "end of line in the text view",
+}
+
+// typingActions are the actions whose keys are checked while text is
+// typed in the text view, so they can't be bound to keys that type a
+// character.
+var typingActions = map[string]bool{
+	"text": true,
+}
+
+// typesCharacter reports whether a key types a character.
+func typesCharacter(key int32) bool {
+	return key >= rl.KeyA && key <= rl.KeyZ || key >= rl.KeyZero && key <= rl.KeyNine || key == rl.KeySpace
+}
+
+// setKey binds an action to a key, both given by name. Once all the keys
+// are set, checkKeys checks that no key is bound to two actions.
+func setKey(action, name string) error {
+	key, ok := keyActions[action]
+	if !ok {
+		return fmt.Errorf("unknown action %s", action)
+	}
+	for keyName, k := range keyNames {
+		if !strings.EqualFold(keyName, name) {
+			continue
+		}
+		if use, ok := reservedKeys[k]; ok {
+			return fmt.Errorf("key %s is reserved for %s", keyName, use)
+		}
+		if typingActions[action] && typesCharacter(k) {
+			return fmt.Errorf("key %s types a character in the text view, %s needs another key", keyName, action)
+		}
+		*key = k
+		return nil
+	}
+	return fmt.Errorf("unknown key %s", name)
+}
+
+// checkKeys returns an error if a key is bound to more than one action.
+func checkKeys() error {
+	actions := slices.Sorted(maps.Keys(keyActions))
+	for i, action := range actions {
+		for _, other := range actions[i+1:] {
+			if *keyActions[action] == *keyActions[other] {
+				return fmt.Errorf("key %s is bound to both %s and %s", keyName(*keyActions[action]), action, other)
+			}
+		}
+	}
+	return nil
+}
+
+// keyName returns the name of a key, for the hints.
+func keyName(key int32) string {
+	for name, k := range keyNames {
+		if k == key {
+			return name
+		}
+	}
+	return "?"
+}
@@ -3,13 +3,15 @@ package main
 // TODO : pictures should be part of button?
 // TODO : Make the zoomed in picture show a loading indicator
 // TODO : (Impossible?) Instead of passing x and y for each pixel, pass a slice of all the arguments
-// TODO : Make the String functions output valid go code and make a program that will execute it
 // TODO : Do a grayscale picture, or an HSV picture, or a black and white image (<0.5)
 // TODO :
 
 import (
-	"math/rand"
+	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 	"unsafe"
 
@@ -18,24 +20,42 @@ import (
 	"github.com/hultan/evolvingImage/picture"
 )
 
-const (
-	mutationRate = 10
-)
-
 type stateType int
 
 const (
 	stateInit stateType = iota
 	stateSelect
 	stateZoom
+	stateEdit
 )
 
 var screenWidth, screenHeight int32 = 1600, 900
-var rows, cols, numPics int32 = 5, 5, rows * cols
+
+// quality is the fraction of the resolution that the pictures are
+// rendered at, they are scaled up to their size.
+var quality = 1.0
+
+// outputDir is where the pictures are saved, and the session is autosaved.
+var outputDir = "."
+
+// rows and cols are the size of the grid of pictures, and numPics is the
+// size of the population. Populations that don't fit in the grid are shown
+// a page at a time.
+var rows, cols, numPics int32 = 5, 5, 0
+var page int32
+
+const maxGridSize = 12
+
 var picWidth, picHeight = int32(float32(screenWidth/cols) * 0.9), int32(float32(screenHeight/rows) * 0.8)
-var imageChannel = make(chan ImageResult, numPics)
-var buttons = make([]*Button, numPics)
-var pictures = make([]*picture.Picture, numPics)
+var imageChannel = make(chan ImageResult, 64)
+var buttons []*Button
+var pictures []*picture.Picture
+
+// renders counts the renders of pictures, and rendered holds the render
+// that each picture waits for, so that images rendered for an older layout,
+// or for a picture that has been replaced since, are dropped.
+var renders int
+var rendered []int
 var state GuiState
 var evolveButton *Button
 
@@ -44,107 +64,271 @@ type GuiState struct {
 	zoomedIn  time.Time
 	zoomImage rl.Texture2D
 	zoomTree  *picture.Picture
+	zoomIndex int // index of zoomTree in pictures, or -1
 }
 
 type ImageResult struct {
-	Image *rl.Image
-	index int32
+	Image  *rl.Image
+	index  int32
+	render int
 }
 
 func main() {
+	flag.IntVar(&config.mutationRate, "mutation", config.mutationRate, "the number of mutations of a new picture is random, below this")
+	flag.IntVar(&config.complexity.Min, "complexitymin", config.complexity.Min, "minimum number of operators added to a tree by the random initialisation")
+	flag.IntVar(&config.complexity.Max, "complexitymax", config.complexity.Max, "maximum number of operators added to a tree by the random initialisation")
+	flag.TextVar(&config.colors, "colors", config.colors, "color model of the pictures : rgb, hsv or gray")
+	flag.TextVar(&config.crossover, "crossover", config.crossover, "crossover mode : subtree, channel, uniform or sizefair")
+	flag.IntVar(&config.limits.MaxDepth, "maxdepth", config.limits.MaxDepth, "maximum depth of a channel tree (0 = no limit)")
+	flag.IntVar(&config.limits.MaxNodes, "maxnodes", config.limits.MaxNodes, "maximum number of nodes in a channel tree (0 = no limit)")
+	flag.TextVar(&config.init, "init", config.init, "initialisation of new populations : random, grow, full or ramped")
+	flag.IntVar(&config.initMinDepth, "initmin", config.initMinDepth, "minimum tree depth for the grow, full and ramped initialisations")
+	flag.IntVar(&config.initMaxDepth, "initmax", config.initMaxDepth, "maximum tree depth for the grow, full and ramped initialisations")
+	flag.BoolVar(&config.simplify, "simplify", config.simplify, "simplify pictures before rendering and saving them")
+	flag.BoolVar(&config.novelty, "novelty", config.novelty, "start in novelty search mode (toggle with N)")
+	flag.IntVar(&config.candidates, "candidates", config.candidates, "candidates bred per picture in novelty search mode")
+	flag.BoolVar(&config.dedupe, "dedupe", config.dedupe, "replace duplicate pictures in a new generation")
+	flag.BoolVar(&config.phenotype, "phenotype", config.phenotype, "also treat pictures that look identical as duplicates")
+	operators := flag.String("operators", "", "file with operator weights, one \"name = weight|on|off\" per line")
+	flag.Int64Var(&config.seed, "seed", config.seed, "seed of the random generator (default random)")
+	sessionFile := flag.String("session", "", "resume the session in this file (if it exists), and save it there on exit")
+	flag.Var(int32Flag{&rows}, "rows", "rows of pictures on a page (change with Ctrl +/-)")
+	flag.Var(int32Flag{&cols}, "cols", "columns of pictures on a page (change with Ctrl +/-)")
+	flag.Var(int32Flag{&numPics}, "population", "pictures in a population, shown in pages if they don't fit (default rows * cols, change with +/-)")
+	flag.Var(int32Flag{&screenWidth}, "width", "width of the window")
+	flag.Var(int32Flag{&screenHeight}, "height", "height of the window")
+	flag.Var(int32Flag{&fontSize}, "fontsize", "size of the text of the Evolve! button")
+	flag.Float64Var(&quality, "quality", quality, "fraction of the resolution that pictures are rendered at, lower is faster")
+	flag.StringVar(&outputDir, "output", outputDir, "directory of the saved pictures and of the autosaved session")
+	configFile := flag.String("config", "", "file with settings, one \"flag = value\" per line, for any of these flags (default "+defaultConfigFile+", if found)")
+	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+
+	if *configFile == "" {
+		*configFile = findConfig()
+	}
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	// The settings saved by the settings panel override the config file,
+	// so that the changes made in the panel are kept
+	defaultOperators = apt.Operators()
+	defaultSettings = settingValues()
+	if fileName, err := settingsFileName(); err == nil {
+		if _, err := os.Stat(fileName); err == nil {
+			if err := loadConfig(fileName); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+	}
+
+	var resumed []*picture.Picture
+	if *sessionFile != "" {
+		if _, err := os.Stat(*sessionFile); err == nil {
+			pics, err := loadSession(*sessionFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			resumed = pics
+		}
+	}
+
+	screenWidth, screenHeight = max(screenWidth, 400), max(screenHeight, 300)
+	fontSize = max(fontSize, 8)
+	quality = min(max(quality, 0.05), 1)
+	rows = min(max(rows, 1), maxGridSize)
+	cols = min(max(cols, 1), maxGridSize)
+	if numPics <= 0 {
+		numPics = rows * cols
+	}
+
+	if *operators != "" {
+		if err := loadOperatorConfig(*operators); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	config.mutationRate = max(config.mutationRate, 1)
+	config.complexity.Min = max(config.complexity.Min, 0)
+	config.complexity.Max = max(config.complexity.Max, config.complexity.Min)
+	picture.SetLimits(config.limits)
+	picture.SetComplexity(config.complexity)
+
 	rl.SetConfigFlags(rl.FlagWindowResizable)
 	rl.InitWindow(screenWidth, screenHeight, "Evolving Images")
 	rl.SetTraceLogLevel(rl.LogNone)
 
 	state = GuiState{zoom: stateInit}
 
-	// Handle parsing of an .apt file
-	args := os.Args
-	if len(args) > 1 {
-		handleParsing(args[1])
+	// Handle parsing of an .apt file, or the diff of two
+	if flag.NArg() > 1 {
+		handleDiff(flag.Arg(0), flag.Arg(1))
+	} else if flag.NArg() > 0 {
+		handleParsing(flag.Arg(0))
 	}
 
 	rl.SetTargetFPS(60)
 	for !rl.WindowShouldClose() {
 		// Update
 		if rl.IsWindowResized() {
-			onGenerateNewImages()
+			relayout()
 		}
 
 		if state.zoom == stateInit {
-			onGenerateNewImages()
+			if resumed != nil {
+				breeder = newBreeder()
+				showPictures(resumed)
+				// The population size can be changed by a flag
+				setPopulationSize(numPics)
+				resumed = nil
+			} else {
+				onGenerateNewImages()
+			}
 			state.zoom = stateSelect
 		}
 
-		if evolveButton != nil {
+		if evolveButton != nil && !editing() {
 			evolveButton.update()
 		}
 
-		if rl.IsKeyPressed(rl.KeyS) && state.zoom == stateZoom {
-			state.zoomTree.Save()
+		if state.zoom == stateEdit {
+			editor.update()
+		} else if textPanel != nil {
+			textPanel.update()
+		} else if diffPanel != nil {
+			diffPanel.update()
+		} else if settingsPanel != nil {
+			settingsPanel.update()
+		} else if rl.IsKeyPressed(keys.edit) && state.zoom == stateZoom {
+			openEditor()
+		} else if rl.IsKeyPressed(keys.text) && state.zoom == stateZoom {
+			openTextView()
+		} else if rl.IsKeyPressed(keys.diff) && state.zoom == stateZoom {
+			openDiffView()
+		} else if rl.IsKeyPressed(keys.settings) && state.zoom == stateSelect {
+			openSettings()
+		}
+
+		if rl.IsKeyPressed(keys.save) && state.zoom == stateZoom && !editing() {
+			savePicture(state.zoomTree, state.zoomImage.Width, state.zoomImage.Height)
 		}
 
-		if rl.IsKeyPressed(rl.KeyF5) {
+		if rl.IsKeyPressed(keys.newImages) && !editing() {
 			onGenerateNewImages()
 		}
 
+		if rl.IsKeyPressed(keys.saveSession) && !editing() {
+			if err := saveSession(sessionFileName(*sessionFile)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+
+		if rl.IsKeyPressed(keys.novelty) && !editing() {
+			config.novelty = !config.novelty
+		}
+
+		if state.zoom == stateSelect && !editing() {
+			updateGrid()
+		}
+
 		// Draw
 		rl.BeginDrawing()
 		rl.ClearBackground(rl.Black)
 
 		if state.zoom == stateZoom {
 			if time.Since(state.zoomedIn).Seconds() > 1 && rl.IsMouseButtonPressed(rl.MouseButtonRight) {
+				if textPanel != nil {
+					closeTextView()
+				}
+				if diffPanel != nil {
+					closeDiffView()
+				}
 				state.zoom = stateSelect
 			}
 			rl.DrawTexture(state.zoomImage, 0, 0, rl.White)
+			rl.DrawText(sizeLabel(state.zoomTree), 25, screenHeight-80, 24, rl.LightGray)
+			if textPanel != nil {
+				textPanel.draw()
+			}
+			if diffPanel != nil {
+				diffPanel.draw()
+			}
+		} else if state.zoom == stateEdit {
+			editor.draw()
 		} else if state.zoom == stateSelect {
 			evolveButton.draw()
+			label := fmt.Sprintf("Generation %d", generation)
+			if pages() > 1 {
+				label += fmt.Sprintf(", page %d of %d", page+1, pages())
+			}
+			rl.DrawText(label, 25, screenHeight-80, 24, rl.LightGray)
 
 			select {
 			case img, ok := <-imageChannel:
-				if ok {
-					// Calculate image x,y position (1-3,1-3)
-					xi := img.index % cols
-					yi := (img.index - xi) / cols
-					// Calculate image screen x,y position (in pixels)
-					x := xi * picWidth
-					y := yi * picHeight
-					// Calculate padding around images
-					xPadding := int32(float32(screenWidth) * 0.1 / float32(cols+1))
-					yPadding := int32(float32(screenHeight) * 0.1 / float32(rows+1))
-					// Add padding to the screen position
-					x += xPadding * (int32(xi) + 1)
-					y += yPadding * (int32(yi) + 1)
-
-					rec := rl.Rectangle{
-						X:      float32(x),
-						Y:      float32(y),
-						Width:  float32(picWidth),
-						Height: float32(picHeight),
-					}
+				if ok && int(img.index) < len(rendered) && img.render == rendered[img.index] {
+					rec := pictureRectangle(img.index)
 					buttons[img.index] = newButton(img.index, rec, rl.LoadTextureFromImage(img.Image), onFullScreen)
+					buttons[img.index].Label = sizeLabel(pictures[img.index])
+					if pendingSelection != nil {
+						buttons[img.index].Selected = pendingSelection[img.index]
+					}
 				}
 			default:
 				// Do nothing
 			}
 
-			// Draw textures at the correct position
+			// Draw the textures of the page at the correct position
 			for _, b := range buttons {
-				if b != nil {
-					b.update()
+				if b != nil && b.Index/(rows*cols) == page {
+					if !editing() {
+						b.update()
+					}
 					b.draw()
 				}
 			}
 		}
 
 		x := screenWidth - 430
-		rl.DrawText("Left mouse click : select an image.", x, screenHeight-80, 24, rl.LightGray)
-		rl.DrawText("Right mouse click : zoom in/out.", x, screenHeight-50, 24, rl.LightGray)
+		if state.zoom == stateZoom {
+			rl.DrawText(fmt.Sprintf("%s : edit, %s : as text, %s : diff.",
+				keyName(keys.edit), keyName(keys.text), keyName(keys.diff)), x, screenHeight-80, 24, rl.LightGray)
+			rl.DrawText("Right mouse click : zoom in/out.", x, screenHeight-50, 24, rl.LightGray)
+		} else if state.zoom != stateEdit {
+			rl.DrawText("Left mouse click : select an image.", x, screenHeight-80, 24, rl.LightGray)
+			rl.DrawText("Right mouse click : zoom in/out.", x, screenHeight-50, 24, rl.LightGray)
+			if state.zoom == stateSelect {
+				rl.DrawText("+/- : population, Ctrl +/- : grid.", x, screenHeight-140, 24, rl.LightGray)
+				rl.DrawText(keyName(keys.settings)+" : settings.", x, screenHeight-170, 24, rl.LightGray)
+			}
+		}
+		if config.novelty {
+			rl.DrawText("Novelty search ("+keyName(keys.novelty)+" to turn off)", x, screenHeight-110, 24, rl.Orange)
+		}
+
+		if settingsPanel != nil {
+			settingsPanel.draw()
+		}
 
 		rl.DrawFPS(25, screenHeight-50)
 		rl.EndDrawing()
 	}
 
+	// Save the session, so that it can be resumed with -session
+	if len(pictures) > 0 {
+		if err := saveSession(sessionFileName(*sessionFile)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
 	// Clean up
 	for i := range buttons {
 		if buttons[i] != nil {
@@ -155,37 +339,114 @@ func main() {
 	rl.CloseWindow()
 }
 
+func loadOperatorConfig(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = apt.LoadOperatorConfig(file); err != nil {
+		return fmt.Errorf("%s : %w", fileName, err)
+	}
+	return nil
+}
+
+// sessionFileName returns the file that the session is saved to.
+func sessionFileName(fileName string) string {
+	if fileName == "" {
+		return filepath.Join(outputDir, autosaveFile)
+	}
+	return fileName
+}
+
 func handleParsing(fileName string) {
-	bytes, err := os.ReadFile(fileName)
+	zoomIn(loadPicture(fileName), -1)
+}
+
+// loadPicture loads an .apt file, and uses its color model.
+func loadPicture(fileName string) *picture.Picture {
+	p, header, err := picture.LoadWithHeader(fileName)
 	if err != nil {
 		panic(err)
 	}
-	str := string(bytes)
-	pictureNode := apt.BeginLexing(str)
-	p := &picture.Picture{
-		R: pictureNode.GetChildren()[0],
-		G: pictureNode.GetChildren()[1],
-		B: pictureNode.GetChildren()[2],
+	if colors, err := picture.ParseColorModel(header.Get("colors")); err == nil {
+		config.colors = colors
 	}
-	zoomIn(p)
+	return p
+}
+
+// handleDiff zooms in on the picture in the second file, and shows how it
+// differs from the picture in the first file, as if that was its parent.
+func handleDiff(parentFile, fileName string) {
+	parent := loadPicture(parentFile)
+	p := loadPicture(fileName)
+	parentOf[p] = parent
+	zoomIn(p, -1)
+	openDiffView()
+}
+
+// sizeLabel returns a short description of the size of the trees in p.
+func sizeLabel(p *picture.Picture) string {
+	return fmt.Sprintf("depth %d, nodes %d", p.Depth(), p.NodeCount())
 }
 
-func zoomIn(p *picture.Picture) {
-	zoomImage := newImage(p, screenWidth, int32(float32(screenHeight)*0.9))
+// zoomIn shows p in zoom mode, index is the index of p in pictures, or -1.
+func zoomIn(p *picture.Picture, index int) {
+	zoomImage := newImage(p, screenWidth, int32(float32(screenHeight)*0.9), currentRenderSettings())
+	if state.zoomImage.ID != 0 {
+		rl.UnloadTexture(state.zoomImage)
+	}
 	state.zoomImage = rl.LoadTextureFromImage(zoomImage)
 	state.zoomTree = p
+	state.zoomIndex = index
 	state.zoom = stateZoom
 	state.zoomedIn = time.Now()
 }
 
+// editing returns true when the tree editor, the text view or the
+// settings panel has the keyboard, and the other keys are off.
+func editing() bool {
+	return state.zoom == stateEdit || textPanel != nil || settingsPanel != nil
+}
+
+// savePicture saves p to the next numbered .apt file, with the settings
+// of the session in the header.
+func savePicture(p *picture.Picture, width, height int32) {
+	if config.simplify {
+		p = p.Simplify()
+	}
+	header := picture.NewHeader()
+	header.Set("colors", config.colors.String())
+	header.Set("seed", strconv.FormatInt(config.seed, 10))
+	header.Set("generation", strconv.Itoa(generation))
+	header.Set("width", strconv.Itoa(int(width)))
+	header.Set("height", strconv.Itoa(int(height)))
+	p.SaveInDirectory(outputDir, header)
+}
+
 func onGenerateNewImages() {
+	nextGeneration()
+	pendingSelection = nil
+	showPictures(breeder.NewPopulation(int(numPics), config.init, config.initMinDepth, config.initMaxDepth))
+}
+
+// showPictures lays out the screen for pics, and renders them.
+func showPictures(pics []*picture.Picture) {
 	screenWidth = int32(rl.GetScreenWidth())
 	screenHeight = int32(rl.GetScreenHeight())
 	picWidth = int32(float32(screenWidth/cols) * 0.9)
 	picHeight = int32(float32(screenHeight/rows) * 0.8)
-	for i := range pictures {
-		pictures[i] = picture.NewPicture()
+	pictures = pics
+	page = min(page, pages()-1)
+
+	for _, b := range buttons {
+		if b != nil {
+			rl.UnloadTexture(b.Texture)
+		}
 	}
+	buttons = make([]*Button, len(pics))
+	rendered = make([]int, len(pics))
 
 	evolveRect := rl.Rectangle{
 		X:      float32(screenWidth)/2 - float32(picWidth)/2,
@@ -195,99 +456,201 @@ func onGenerateNewImages() {
 	}
 	evolveButton = newTextButton(evolveRect, "Evolve!", onEvolveButtonClicked)
 
-	for i := range buttons {
-		go func(i int) {
-			image := newImage(pictures[i], picWidth, picHeight)
-			imageChannel <- ImageResult{
-				image,
-				int32(i),
+	for i := range pictures {
+		renderPicture(i)
+	}
+}
+
+// renderPicture renders picture i in the background, for the current
+// layout. Earlier renders of picture i are dropped when they arrive.
+func renderPicture(i int) {
+	renders++
+	rendered[i] = renders
+	p, width, height, render := pictures[i], picWidth, picHeight, renders
+	settings := currentRenderSettings()
+	go func() {
+		imageChannel <- ImageResult{
+			newImage(p, width, height, settings),
+			int32(i),
+			render,
+		}
+	}()
+}
+
+// pages returns the number of pages needed to show the pictures.
+func pages() int32 {
+	return max((int32(len(pictures))+rows*cols-1)/(rows*cols), 1)
+}
+
+// pictureRectangle returns where picture i is shown, on its page.
+func pictureRectangle(i int32) rl.Rectangle {
+	// Calculate image x,y position in the grid
+	i %= rows * cols
+	xi := i % cols
+	yi := i / cols
+	// Calculate image screen x,y position (in pixels)
+	x := xi * picWidth
+	y := yi * picHeight
+	// Calculate padding around images
+	xPadding := int32(float32(screenWidth) * 0.1 / float32(cols+1))
+	yPadding := int32(float32(screenHeight) * 0.1 / float32(rows+1))
+	// Add padding to the screen position
+	x += xPadding * (xi + 1)
+	y += yPadding * (yi + 1)
+
+	return rl.Rectangle{
+		X:      float32(x),
+		Y:      float32(y),
+		Width:  float32(picWidth),
+		Height: float32(picHeight),
+	}
+}
+
+// relayout lays out the pictures again, after the grid or the window has
+// changed size. The selection is kept.
+func relayout() {
+	if len(pictures) == 0 {
+		return
+	}
+	pendingSelection = selection()
+	showPictures(pictures)
+}
+
+// setPopulationSize changes the size of the population. When it grows,
+// new random pictures are added at the end of the current generation, and
+// when it shrinks, the pictures at the end are removed.
+func setPopulationSize(n int32) {
+	n = max(n, 1)
+	numPics = n
+	old := int32(len(pictures))
+	switch {
+	case n < old:
+		for _, b := range buttons[n:] {
+			if b != nil {
+				rl.UnloadTexture(b.Texture)
 			}
-		}(i)
+		}
+		pictures, buttons, rendered = pictures[:n], buttons[:n], rendered[:n]
+		if pendingSelection != nil {
+			pendingSelection = pendingSelection[:n]
+		}
+		page = min(page, pages()-1)
+	case n > old:
+		pics := breeder.NewPopulation(int(n-old), config.init, config.initMinDepth, config.initMaxDepth)
+		pictures = append(pictures, pics...)
+		buttons = append(buttons, make([]*Button, n-old)...)
+		rendered = append(rendered, make([]int, n-old)...)
+		if pendingSelection != nil {
+			pendingSelection = append(pendingSelection, make([]bool, n-old)...)
+		}
+		for i := old; i < n; i++ {
+			renderPicture(int(i))
+		}
+	}
+}
+
+// updateGrid handles the keys that change the population size and the
+// grid, and that turn the pages.
+func updateGrid() {
+	plus := isKeyPressed(rl.KeyEqual) || isKeyPressed(rl.KeyKpAdd)
+	minus := isKeyPressed(rl.KeyMinus) || isKeyPressed(rl.KeyKpSubtract)
+	control := rl.IsKeyDown(rl.KeyLeftControl) || rl.IsKeyDown(rl.KeyRightControl)
+	switch {
+	case control && (plus || minus):
+		// Like zooming, bigger pictures with plus and smaller with minus
+		step := int32(1)
+		if plus {
+			step = -1
+		}
+		newRows := min(max(rows+step, 1), maxGridSize)
+		newCols := min(max(cols+step, 1), maxGridSize)
+		if newRows != rows || newCols != cols {
+			rows, cols = newRows, newCols
+			relayout()
+		}
+	case plus:
+		// A row of pictures more
+		setPopulationSize(numPics + cols)
+	case minus:
+		setPopulationSize(numPics - cols)
+	case isKeyPressed(rl.KeyPageDown) || isKeyPressed(rl.KeyRight):
+		page = min(page+1, pages()-1)
+	case isKeyPressed(rl.KeyPageUp) || isKeyPressed(rl.KeyLeft):
+		page = max(page-1, 0)
 	}
 }
 
 func onFullScreen(button *Button) {
 	if state.zoom == stateSelect {
-		zoomIn(pictures[button.Index])
+		zoomIn(pictures[button.Index], int(button.Index))
 	}
 }
 
 func onEvolveButtonClicked() {
 	selectedPictures := make([]*picture.Picture, 0)
-	for i, button := range buttons {
-		if button.Selected {
+	for i := range buttons {
+		if isSelected(i) {
 			selectedPictures = append(selectedPictures, pictures[i])
 		}
 	}
 
-	if len(selectedPictures) != 0 {
-		for i := range buttons {
-			buttons[i] = nil
-		}
+	// In novelty search mode, all pictures are parents if none are selected
+	if config.novelty && len(selectedPictures) == 0 {
+		selectedPictures = pictures
+	}
 
-		pictures = evolve(selectedPictures)
-		for i := range pictures {
-			go func(i int) {
-				pixels := newImage(pictures[i], picWidth, picHeight)
-				imageChannel <- ImageResult{
-					pixels,
-					int32(i),
-				}
-			}(i)
+	if len(selectedPictures) != 0 {
+		pendingSelection = nil
+		page = 0
+
+		nextGeneration()
+		if config.novelty {
+			showPictures(evolveNovel(selectedPictures))
+		} else {
+			showPictures(evolve(selectedPictures))
 		}
 	}
 }
 
-func evolve(survivors []*picture.Picture) []*picture.Picture {
-	newPics := make([]*picture.Picture, numPics)
-	i := 0
-	for i < len(survivors) {
-		a := survivors[i]
-		b := survivors[rand.Intn(len(survivors))]
-		newPics[i] = a.Cross(b)
-		i++
-	}
+// renderSettings are the settings that newImage renders with. The
+// settings panel changes them on the main goroutine, so they are read
+// there with currentRenderSettings, before the rendering starts.
+type renderSettings struct {
+	simplify bool
+	colors   picture.ColorModel
+	quality  float64
+}
 
-	for i < len(newPics) {
-		a := survivors[rand.Intn(len(survivors))]
-		b := survivors[rand.Intn(len(survivors))]
-		newPics[i] = a.Cross(b)
-		i++
-	}
+func currentRenderSettings() renderSettings {
+	return renderSettings{config.simplify, config.colors, quality}
+}
 
-	for _, pic := range newPics {
-		r := rand.Intn(mutationRate)
-		for i := 0; i < r; i++ {
-			pic.Mutate()
-		}
+func newImage(p *picture.Picture, width, height int32, settings renderSettings) *rl.Image {
+	if settings.simplify {
+		p = p.Simplify()
 	}
 
-	return newPics
-}
-
-func newImage(p *picture.Picture, width, height int32) *rl.Image {
-	scale := 128.0
-	offset := -1 * scale
-	index := 0
-	var imageData = make([]byte, width*height*4)
-
-	for y := int32(0); y < height; y++ {
-		yy := float64(y)/float64(height)*2 - 1
-		for x := int32(0); x < width; x++ {
-			xx := float64(x)/float64(width)*2 - 1
-			r := p.R.Evaluate(xx, yy)
-			g := p.G.Evaluate(xx, yy)
-			b := p.B.Evaluate(xx, yy)
-
-			imageData[index+0] = byte(r*scale - offset)
-			imageData[index+1] = byte(g*scale - offset)
-			imageData[index+2] = byte(b*scale - offset)
-			imageData[index+3] = 255
-			index += 4
-		}
+	w := max(int(float64(width)*settings.quality), 1)
+	h := max(int(float64(height)*settings.quality), 1)
+	imageData := p.PixelsIn(settings.colors, w, h)
+	if w != int(width) || h != int(height) {
+		imageData = scalePixels(imageData, w, h, int(width), int(height))
 	}
 
 	var image = rl.NewImage(imageData, width, height, 1, rl.UncompressedR8g8b8a8)
 	image.Data = unsafe.Pointer(unsafe.SliceData(imageData))
 	return image
 }
+
+// scalePixels scales RGBA pixels up to a bigger size, by repeating them.
+func scalePixels(pixels []byte, width, height, newWidth, newHeight int) []byte {
+	scaled := make([]byte, newWidth*newHeight*4)
+	for y := 0; y < newHeight; y++ {
+		row := y * height / newHeight * width
+		for x := 0; x < newWidth; x++ {
+			i := (row + x*width/newWidth) * 4
+			copy(scaled[(y*newWidth+x)*4:], pixels[i:i+4])
+		}
+	}
+	return scaled
+}
@@ -135,10 +135,20 @@ func MakeNoise(noiseType TypeOfNoise, frequency, lacunarity, gain float64, octav
  */
 
 func fastFloor(x float64) int {
-	if float64(int(x)) <= x {
+	if float64(toInt(x)) <= x {
+		return toInt(x)
+	}
+	return toInt(x) - 1
+}
+
+// toInt converts x to an int like int(x) does on amd64. Go leaves the
+// conversion of NaN and out of range values to the platform, and for
+// example wasm saturates instead, which would give other noise.
+func toInt(x float64) int {
+	if x >= math.MinInt64 && x < math.MaxInt64 {
 		return int(x)
 	}
-	return int(x) - 1
+	return math.MinInt64
 }
 
 // Static data
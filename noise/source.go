@@ -0,0 +1,15 @@
+package noise
+
+import _ "embed"
+
+// Source is the source code of the noise functions, for code generators
+// that include them in the code that they generate (see apt.GenerateGo).
+//
+//go:embed noise.go
+var Source string
+
+// Permutation returns the permutation table used by Snoise2, for code
+// generators that port the noise functions to other languages.
+func Permutation() [256]uint8 {
+	return perm
+}
@@ -0,0 +1,126 @@
+// Package novelty implements novelty search, where pictures are rewarded for
+// looking different from everything seen so far, instead of for looking like
+// something in particular.
+package novelty
+
+import (
+	"math"
+	"slices"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// featureSize is the width and height of the render used as a feature vector.
+const featureSize = 8
+
+// Features returns the feature vector of a picture, which is a low resolution
+// render with every color component scaled to [0, 1].
+func Features(p *picture.Picture) []float64 {
+	pixels := p.Pixels(featureSize, featureSize)
+	features := make([]float64, 0, featureSize*featureSize*3)
+	for i, component := range pixels {
+		if i%4 != 3 {
+			features = append(features, float64(component)/255)
+		}
+	}
+	return features
+}
+
+// Archive remembers the feature vectors of the novel pictures found so far.
+type Archive struct {
+	// K is the number of nearest neighbours used by Score.
+	K int
+	// MaxSize is the maximum number of vectors kept, the oldest are
+	// dropped first. Zero means no limit.
+	MaxSize int
+
+	vectors [][]float64
+}
+
+// NewArchive creates an empty archive.
+func NewArchive(k, maxSize int) *Archive {
+	return &Archive{K: k, MaxSize: maxSize}
+}
+
+// Len returns the number of feature vectors in the archive.
+func (a *Archive) Len() int {
+	return len(a.vectors)
+}
+
+// Add adds a feature vector to the archive.
+func (a *Archive) Add(features []float64) {
+	a.vectors = append(a.vectors, features)
+	if a.MaxSize > 0 && len(a.vectors) > a.MaxSize {
+		a.vectors = a.vectors[len(a.vectors)-a.MaxSize:]
+	}
+}
+
+// Score returns the novelty of a feature vector, which is the mean distance
+// to its K nearest neighbours among the vectors in the archive and others
+// (typically the rest of the current population). A vector with nothing to
+// compare against is infinitely novel.
+func (a *Archive) Score(features []float64, others [][]float64) float64 {
+	distances := make([]float64, 0, len(a.vectors)+len(others))
+	for _, v := range a.vectors {
+		distances = append(distances, distance(features, v))
+	}
+	for _, v := range others {
+		distances = append(distances, distance(features, v))
+	}
+	if len(distances) == 0 {
+		return math.Inf(1)
+	}
+
+	slices.Sort(distances)
+	k := min(max(a.K, 1), len(distances))
+	sum := 0.0
+	for _, d := range distances[:k] {
+		sum += d
+	}
+	return sum / float64(k)
+}
+
+// Select picks the n most novel of the candidates and adds them to the
+// archive. The pictures are picked one at a time, and every picked picture
+// is taken into account when scoring the remaining candidates, so that the
+// selection is diverse in itself.
+func (a *Archive) Select(candidates []*picture.Picture, n int) []*picture.Picture {
+	features := make([][]float64, len(candidates))
+	for i, c := range candidates {
+		features[i] = Features(c)
+	}
+
+	picked := make([]*picture.Picture, 0, n)
+	var pickedFeatures [][]float64
+	used := make([]bool, len(candidates))
+	for len(picked) < n && len(picked) < len(candidates) {
+		best, bestScore := -1, -1.0
+		for i := range candidates {
+			if used[i] {
+				continue
+			}
+			score := a.Score(features[i], pickedFeatures)
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		used[best] = true
+		picked = append(picked, candidates[best])
+		pickedFeatures = append(pickedFeatures, features[best])
+	}
+
+	for _, f := range pickedFeatures {
+		a.Add(f)
+	}
+	return picked
+}
+
+// distance returns the euclidean distance between two feature vectors.
+func distance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,35 @@
+package picture
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+// WriteBinary writes the pictures to w in the binary format of
+// apt.EncodeBinary, as three trees (r, g and b) per picture.
+func WriteBinary(w io.Writer, pictures []*Picture) error {
+	trees := make([]apt.Node, 0, len(pictures)*3)
+	for _, p := range pictures {
+		trees = append(trees, p.R, p.G, p.B)
+	}
+	return apt.EncodeBinary(w, trees)
+}
+
+// ReadBinary reads pictures written by WriteBinary.
+func ReadBinary(r io.Reader) ([]*Picture, error) {
+	trees, err := apt.DecodeBinary(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(trees)%3 != 0 {
+		return nil, fmt.Errorf("%d trees is not a whole number of pictures", len(trees))
+	}
+
+	pictures := make([]*Picture, 0, len(trees)/3)
+	for i := 0; i < len(trees); i += 3 {
+		pictures = append(pictures, &Picture{R: trees[i], G: trees[i+1], B: trees[i+2]})
+	}
+	return pictures, nil
+}
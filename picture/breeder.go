@@ -0,0 +1,64 @@
+package picture
+
+import "github.com/hultan/evolvingImage/apt"
+
+// Breeder creates, crosses and mutates pictures using its own tree generator
+// and limits. A breeder is only as safe for concurrent use as its generator,
+// but separate breeders with separate generators can be used concurrently.
+// The package level functions and the Picture methods use a default breeder,
+// with the default generator, the limits set by SetLimits and the
+// complexity set by SetComplexity.
+type Breeder struct {
+	Generator  *apt.Generator
+	Limits     Limits
+	Complexity Complexity
+}
+
+// NewBreeder creates a breeder, with the complexity set by SetComplexity.
+func NewBreeder(generator *apt.Generator, limits Limits) *Breeder {
+	return &Breeder{
+		Generator:  generator,
+		Limits:     limits,
+		Complexity: complexity,
+	}
+}
+
+func defaultBreeder() *Breeder {
+	return NewBreeder(apt.DefaultGenerator(), limits)
+}
+
+// NewPicture creates a random picture (see the package level NewPicture).
+func (b *Breeder) NewPicture() *Picture {
+	return &Picture{
+		R: b.newRandomNode(),
+		G: b.newRandomNode(),
+		B: b.newRandomNode(),
+	}
+}
+
+// Mutate mutates a random node in one of the channels of p. Mutations that
+// break the limits are rejected, and if no allowed mutation is found the
+// picture is left unchanged.
+func (b *Breeder) Mutate(p *Picture) {
+	original := p.Copy()
+	for i := 0; i < maxAttempts; i++ {
+		p.mutate(b)
+		if b.Limits.Allows(p) {
+			return
+		}
+		*p = *original.Copy()
+	}
+}
+
+// Cross creates a child of p and other, using the given crossover mode.
+// Children that break the limits are rejected, and if no allowed child
+// is found a copy of p is returned.
+func (b *Breeder) Cross(p, other *Picture, mode CrossoverMode) *Picture {
+	for i := 0; i < maxAttempts; i++ {
+		child := p.cross(b.Generator, other, mode)
+		if b.Limits.Allows(child) {
+			return child
+		}
+	}
+	return p.Copy()
+}
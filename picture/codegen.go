@@ -0,0 +1,20 @@
+package picture
+
+import "github.com/hultan/evolvingImage/apt"
+
+// GenerateGo generates a Go source file with a function that computes the
+// picture (see apt.GenerateGo).
+func (p *Picture) GenerateGo(pkg, funcName string) ([]byte, error) {
+	return apt.GenerateGo(p.R, p.G, p.B, pkg, funcName)
+}
+
+// GenerateShader generates a fragment shader that draws the picture (see apt.GenerateShader).
+func (p *Picture) GenerateShader(language apt.ShaderLanguage) (string, error) {
+	return apt.GenerateShader(p.R, p.G, p.B, language)
+}
+
+// GenerateJS generates a JavaScript module that draws the picture on a
+// canvas (see apt.GenerateJS).
+func (p *Picture) GenerateJS() (string, error) {
+	return apt.GenerateJS(p.R, p.G, p.B)
+}
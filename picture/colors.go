@@ -0,0 +1,99 @@
+package picture
+
+import (
+	"fmt"
+	"math"
+)
+
+// ColorModel decides how the values of the three channels of a picture
+// are turned into colors. It is saved as "colors" in the header of .apt
+// files.
+type ColorModel int
+
+const (
+	// ColorsRGB uses the channels as red, green and blue.
+	ColorsRGB ColorModel = iota
+	// ColorsHSV uses the channels as hue, saturation and value.
+	ColorsHSV
+	// ColorsGray uses the average of the channels as a shade of gray.
+	ColorsGray
+)
+
+var colorModelNames = []string{"rgb", "hsv", "gray"}
+
+func (m ColorModel) String() string {
+	if m < 0 || int(m) >= len(colorModelNames) {
+		return fmt.Sprintf("ColorModel(%d)", int(m))
+	}
+	return colorModelNames[m]
+}
+
+// ParseColorModel converts a name returned by ColorModel.String back into a ColorModel.
+func ParseColorModel(s string) (ColorModel, error) {
+	for i, name := range colorModelNames {
+		if name == s {
+			return ColorModel(i), nil
+		}
+	}
+	return ColorsRGB, fmt.Errorf("unknown color model : %s", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that color models
+// can be used in flags and JSON files.
+func (m ColorModel) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *ColorModel) UnmarshalText(text []byte) error {
+	model, err := ParseColorModel(string(text))
+	if err != nil {
+		return err
+	}
+	*m = model
+	return nil
+}
+
+// color returns the red, green and blue components of a pixel with the
+// channel values r, g and b. The values are converted with ToByte first,
+// so that all models wrap around in the same way.
+func (m ColorModel) color(r, g, b float64) (byte, byte, byte) {
+	rb, gb, bb := ToByte(r), ToByte(g), ToByte(b)
+	switch m {
+	case ColorsHSV:
+		return hsvToRGB(float64(rb)/256, float64(gb)/255, float64(bb)/255)
+	case ColorsGray:
+		gray := byte((int(rb) + int(gb) + int(bb)) / 3)
+		return gray, gray, gray
+	default:
+		return rb, gb, bb
+	}
+}
+
+// hsvToRGB converts a color with a hue in [0, 1), and a saturation and
+// value in [0, 1], to red, green and blue.
+func hsvToRGB(h, s, v float64) (byte, byte, byte) {
+	sector := h * 6
+	i := math.Floor(sector)
+	f := sector - i
+	p := v * (1 - s)
+	q := v * (1 - s*f)
+	t := v * (1 - s*(1-f))
+
+	var r, g, b float64
+	switch int(i) {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return byte(math.Round(r * 255)), byte(math.Round(g * 255)), byte(math.Round(b * 255))
+}
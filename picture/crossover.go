@@ -0,0 +1,151 @@
+package picture
+
+import (
+	"fmt"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+// CrossoverMode decides how two parent pictures are combined by CrossWith.
+type CrossoverMode int
+
+const (
+	// CrossoverSubtree grafts one random subtree of the other parent into a copy of the first (see Cross).
+	CrossoverSubtree CrossoverMode = iota
+	// CrossoverChannel takes whole color channels from either parent.
+	CrossoverChannel
+	// CrossoverUniform does a uniform crossover over the homologous positions of each channel.
+	CrossoverUniform
+	// CrossoverSizeFair grafts a donor subtree of similar size, which limits bloat.
+	CrossoverSizeFair
+)
+
+var crossoverModeNames = []string{"subtree", "channel", "uniform", "sizefair"}
+
+func (m CrossoverMode) String() string {
+	if m < 0 || int(m) >= len(crossoverModeNames) {
+		return fmt.Sprintf("CrossoverMode(%d)", int(m))
+	}
+	return crossoverModeNames[m]
+}
+
+// ParseCrossoverMode converts a name returned by CrossoverMode.String back into a CrossoverMode.
+func ParseCrossoverMode(s string) (CrossoverMode, error) {
+	for i, name := range crossoverModeNames {
+		if name == s {
+			return CrossoverMode(i), nil
+		}
+	}
+	return CrossoverSubtree, fmt.Errorf("unknown crossover mode : %s", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that crossover modes
+// can be used in flags and JSON files.
+func (m CrossoverMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *CrossoverMode) UnmarshalText(text []byte) error {
+	mode, err := ParseCrossoverMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// CrossWith creates a child of p and other, using the given crossover mode.
+// Children that break the limits are rejected, and if no allowed child is
+// found a copy of p is returned.
+func (p *Picture) CrossWith(other *Picture, mode CrossoverMode) *Picture {
+	return defaultBreeder().Cross(p, other, mode)
+}
+
+func (p *Picture) cross(g *apt.Generator, other *Picture, mode CrossoverMode) *Picture {
+	switch mode {
+	case CrossoverSubtree:
+		return p.crossSubtree(g.Rand(), other)
+	case CrossoverChannel:
+		return p.crossChannels(g.Rand(), other)
+	case CrossoverUniform:
+		return p.crossUniform(g, other)
+	case CrossoverSizeFair:
+		return p.crossSizeFair(g, other)
+	default:
+		panic(fmt.Sprintf("CrossWith : unknown crossover mode %d", mode))
+	}
+}
+
+// crossChannels takes each channel from either parent, making sure
+// that both parents contribute at least one channel.
+func (p *Picture) crossChannels(r apt.Source, other *Picture) *Picture {
+	child := &Picture{}
+	mask := r.Intn(6) + 1 // 1-6, never all from p (0) or all from other (7)
+
+	channels := []struct {
+		dst      *apt.Node
+		own, oth apt.Node
+	}{
+		{&child.R, p.R, other.R},
+		{&child.G, p.G, other.G},
+		{&child.B, p.B, other.B},
+	}
+	for i, c := range channels {
+		if mask&(1<<i) == 0 {
+			*c.dst = apt.CopyTree(c.own, nil)
+		} else {
+			*c.dst = apt.CopyTree(c.oth, nil)
+		}
+	}
+	return child
+}
+
+func (p *Picture) crossUniform(g *apt.Generator, other *Picture) *Picture {
+	return &Picture{
+		R: g.UniformCrossover(p.R, other.R),
+		G: g.UniformCrossover(p.G, other.G),
+		B: g.UniformCrossover(p.B, other.B),
+	}
+}
+
+func (p *Picture) crossSizeFair(g *apt.Generator, other *Picture) *Picture {
+	child := p.Copy()
+	channel := child.pickRandomChannel(g.Rand())
+
+	aIndex := g.Rand().Intn((*channel).NodeCount())
+	aNode, _ := apt.GetNthNode(*channel, aIndex, 0)
+
+	bNode := g.SizeFairDonor(other.pickRandomColor(g.Rand()), aNode.NodeCount())
+	bNodeCopy := apt.CopyTree(bNode, nil)
+
+	if aNode == *channel {
+		// The root of the channel is replaced
+		*channel = bNodeCopy
+	} else {
+		apt.ReplaceNode(aNode, bNodeCopy)
+	}
+	return child
+}
+
+// Copy returns a deep copy of the picture.
+func (p *Picture) Copy() *Picture {
+	return &Picture{
+		R: apt.CopyTree(p.R, nil),
+		G: apt.CopyTree(p.G, nil),
+		B: apt.CopyTree(p.B, nil),
+	}
+}
+
+func (p *Picture) pickRandomChannel(r apt.Source) *apt.Node {
+	switch r.Intn(3) {
+	case 0:
+		return &p.R
+	case 1:
+		return &p.G
+	case 2:
+		return &p.B
+	default:
+		panic("pickRandomChannel : Should not happen!")
+	}
+}
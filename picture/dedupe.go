@@ -0,0 +1,91 @@
+package picture
+
+import "github.com/hultan/evolvingImage/apt"
+
+// phenotypeSize is the width and height of the renders compared by a phenotype check.
+const phenotypeSize = 16
+
+// DedupeOptions decides when two pictures are considered duplicates.
+type DedupeOptions struct {
+	// Tolerance is the constant tolerance of the structural hash (see apt.Hash).
+	Tolerance float64
+	// Phenotype also compares low resolution renders of the pictures, to
+	// catch pictures that look the same but are structurally different.
+	Phenotype bool
+	// Threshold is the largest mean difference, per color component
+	// (0-255), of two renders that are considered identical.
+	Threshold float64
+}
+
+// DefaultDedupeOptions are sensible options for Deduplicate.
+var DefaultDedupeOptions = DedupeOptions{
+	Tolerance: 1e-6,
+	Phenotype: false,
+	Threshold: 1,
+}
+
+// Hash returns a structural hash of the picture (see apt.Hash).
+func (p *Picture) Hash(tolerance float64) uint64 {
+	return apt.Hash(p.R, tolerance)*31*31 + apt.Hash(p.G, tolerance)*31 + apt.Hash(p.B, tolerance)
+}
+
+// Deduplicate replaces every picture in pictures that is a duplicate of an
+// earlier picture, with a new picture created by newPicture (for example a
+// fresh crossover). A replacement that is a duplicate itself is retried a
+// few times before it is accepted anyway. The number of replaced pictures is
+// returned.
+func Deduplicate(pictures []*Picture, options DedupeOptions, newPicture func() *Picture) int {
+	hashes := make(map[uint64]bool)
+	var renders [][]byte
+	replaced := 0
+
+	for i := range pictures {
+		for attempt := 0; ; attempt++ {
+			hash := pictures[i].Hash(options.Tolerance)
+			var render []byte
+			if options.Phenotype {
+				render = pictures[i].Pixels(phenotypeSize, phenotypeSize)
+			}
+
+			duplicate := hashes[hash] || (options.Phenotype && looksLikeAny(render, renders, options.Threshold))
+			if !duplicate || attempt == maxAttempts {
+				hashes[hash] = true
+				renders = append(renders, render)
+				break
+			}
+
+			if attempt == 0 {
+				replaced++
+			}
+			pictures[i] = newPicture()
+		}
+	}
+	return replaced
+}
+
+func looksLikeAny(render []byte, renders [][]byte, threshold float64) bool {
+	for _, other := range renders {
+		if meanDifference(render, other) <= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// meanDifference returns the mean absolute difference of the color
+// components (skipping alpha) of two renders of the same size.
+func meanDifference(a, b []byte) float64 {
+	sum, count := 0, 0
+	for i := range a {
+		if i%4 == 3 {
+			continue
+		}
+		d := int(a[i]) - int(b[i])
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+		count++
+	}
+	return float64(sum) / float64(count)
+}
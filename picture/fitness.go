@@ -0,0 +1,46 @@
+package picture
+
+import "image"
+
+// FitnessFunc scores a picture automatically, a higher score is better.
+type FitnessFunc func(p *Picture) float64
+
+// WithParsimony adds parsimony pressure to a fitness function, every
+// node in the picture lowers the score by coefficient. A coefficient
+// of zero returns fitness unchanged.
+func WithParsimony(fitness FitnessFunc, coefficient float64) FitnessFunc {
+	if coefficient == 0 {
+		return fitness
+	}
+	return func(p *Picture) float64 {
+		return fitness(p) - coefficient*float64(p.NodeCount())
+	}
+}
+
+// TargetFitness returns a fitness function that rewards pictures for looking
+// like target. The picture is rendered at size x size pixels and compared to
+// the target scaled to the same size. The score is minus the mean squared
+// difference of the color components (scaled to [0, 1]), so a perfect match
+// scores 0.
+func TargetFitness(target image.Image, size int) FitnessFunc {
+	bounds := target.Bounds()
+	components := make([]float64, 0, size*size*3)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			tx := bounds.Min.X + x*bounds.Dx()/size
+			ty := bounds.Min.Y + y*bounds.Dy()/size
+			r, g, b, _ := target.At(tx, ty).RGBA()
+			components = append(components, float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+		}
+	}
+
+	return func(p *Picture) float64 {
+		pixels := p.Pixels(size, size)
+		sum := 0.0
+		for i, c := range components {
+			d := float64(pixels[i/3*4+i%3])/255 - c
+			sum += d * d
+		}
+		return -sum / float64(len(components))
+	}
+}
@@ -0,0 +1,100 @@
+package picture
+
+import (
+	"fmt"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+// InitMethod decides how the trees of a new population are built.
+type InitMethod int
+
+const (
+	// InitRandom adds a random number of operators at random positions (see NewPicture).
+	InitRandom InitMethod = iota
+	// InitGrow builds trees with apt.Grow.
+	InitGrow
+	// InitFull builds trees with apt.Full.
+	InitFull
+	// InitRamped builds trees with apt.RampedHalfAndHalf.
+	InitRamped
+)
+
+var initMethodNames = []string{"random", "grow", "full", "ramped"}
+
+func (m InitMethod) String() string {
+	if m < 0 || int(m) >= len(initMethodNames) {
+		return fmt.Sprintf("InitMethod(%d)", int(m))
+	}
+	return initMethodNames[m]
+}
+
+// ParseInitMethod converts a name returned by InitMethod.String back into an InitMethod.
+func ParseInitMethod(s string) (InitMethod, error) {
+	for i, name := range initMethodNames {
+		if name == s {
+			return InitMethod(i), nil
+		}
+	}
+	return InitRandom, fmt.Errorf("unknown init method : %s", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that init methods
+// can be used in flags and JSON files.
+func (m InitMethod) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *InitMethod) UnmarshalText(text []byte) error {
+	method, err := ParseInitMethod(string(text))
+	if err != nil {
+		return err
+	}
+	*m = method
+	return nil
+}
+
+// NewPopulation creates n new pictures using the given method. The depths
+// are only used by the grow, full and ramped methods. For the full method
+// the depth of each picture is ramped between minDepth and maxDepth.
+func NewPopulation(n int, method InitMethod, minDepth, maxDepth int) []*Picture {
+	return defaultBreeder().NewPopulation(n, method, minDepth, maxDepth)
+}
+
+// NewPopulation works like the package level NewPopulation, using the breeder.
+func (b *Breeder) NewPopulation(n int, method InitMethod, minDepth, maxDepth int) []*Picture {
+	if maxDepth < minDepth {
+		maxDepth = minDepth
+	}
+
+	trees := make([]apt.Node, 3*n)
+	switch method {
+	case InitRandom:
+		for i := range trees {
+			trees[i] = b.newRandomNode()
+		}
+	case InitGrow:
+		for i := range trees {
+			trees[i] = b.Generator.Grow(minDepth, maxDepth)
+		}
+	case InitFull:
+		for i := range trees {
+			trees[i] = b.Generator.Full(minDepth + (i/3)%(maxDepth-minDepth+1))
+		}
+	case InitRamped:
+		trees = b.Generator.RampedHalfAndHalf(3*n, minDepth, maxDepth)
+	default:
+		panic(fmt.Sprintf("NewPopulation : unknown init method %d", method))
+	}
+
+	pictures := make([]*Picture, n)
+	for i := range pictures {
+		pictures[i] = &Picture{
+			R: b.Limits.repair(b.Generator, trees[3*i]),
+			G: b.Limits.repair(b.Generator, trees[3*i+1]),
+			B: b.Limits.repair(b.Generator, trees[3*i+2]),
+		}
+	}
+	return pictures
+}
@@ -0,0 +1,57 @@
+package picture
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+// jsonPicture is the JSON form of a picture, {"r": tree, "g": tree, "b": tree},
+// where the trees are in the JSON form described in apt.MarshalJSON.
+type jsonPicture struct {
+	R json.RawMessage `json:"r"`
+	G json.RawMessage `json:"g"`
+	B json.RawMessage `json:"b"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p *Picture) MarshalJSON() ([]byte, error) {
+	var jp jsonPicture
+	var err error
+	if jp.R, err = apt.MarshalJSON(p.R); err != nil {
+		return nil, err
+	}
+	if jp.G, err = apt.MarshalJSON(p.G); err != nil {
+		return nil, err
+	}
+	if jp.B, err = apt.MarshalJSON(p.B); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Picture) UnmarshalJSON(data []byte) error {
+	var jp jsonPicture
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	if jp.R == nil || jp.G == nil || jp.B == nil {
+		return errors.New("a picture needs the channels r, g and b")
+	}
+
+	var q Picture
+	var err error
+	if q.R, err = apt.UnmarshalJSON(jp.R); err != nil {
+		return err
+	}
+	if q.G, err = apt.UnmarshalJSON(jp.G); err != nil {
+		return err
+	}
+	if q.B, err = apt.UnmarshalJSON(jp.B); err != nil {
+		return err
+	}
+	*p = q
+	return nil
+}
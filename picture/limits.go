@@ -0,0 +1,74 @@
+package picture
+
+import "github.com/hultan/evolvingImage/apt"
+
+// maxAttempts is the number of times a crossover or mutation is retried
+// before the offending offspring is rejected.
+const maxAttempts = 10
+
+// Limits bounds the size of each of the channel trees in a picture.
+// A value of zero means that there is no limit.
+type Limits struct {
+	MaxDepth int
+	MaxNodes int
+}
+
+var limits = Limits{
+	MaxDepth: 17,
+	MaxNodes: 250,
+}
+
+// SetLimits sets the limits that NewPicture, NewPopulation, Cross, CrossWith
+// and Mutate enforce. Breeders have their own limits.
+func SetLimits(l Limits) {
+	limits = l
+}
+
+// GetLimits returns the limits that are currently enforced.
+func GetLimits() Limits {
+	return limits
+}
+
+// Allows returns true if all channels of p are within the limits.
+func (l Limits) Allows(p *Picture) bool {
+	for _, channel := range []apt.Node{p.R, p.G, p.B} {
+		if !l.allowsNode(channel) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l Limits) allowsNode(node apt.Node) bool {
+	if l.MaxDepth > 0 && apt.Depth(node) > l.MaxDepth {
+		return false
+	}
+	if l.MaxNodes > 0 && node.NodeCount() > l.MaxNodes {
+		return false
+	}
+	return true
+}
+
+// repair prunes a tree until it is within the limits.
+func (l Limits) repair(g *apt.Generator, node apt.Node) apt.Node {
+	depth := apt.Depth(node)
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		depth = l.MaxDepth
+		node = g.Prune(node, depth)
+	}
+	for l.MaxNodes > 0 && node.NodeCount() > l.MaxNodes && depth > 1 {
+		depth--
+		node = g.Prune(node, depth)
+	}
+	return node
+}
+
+// Depth returns the depth of the deepest channel tree.
+func (p *Picture) Depth() int {
+	return max(apt.Depth(p.R), apt.Depth(p.G), apt.Depth(p.B))
+}
+
+// NodeCount returns the total number of nodes in all channels.
+func (p *Picture) NodeCount() int {
+	return p.R.NodeCount() + p.G.NodeCount() + p.B.NodeCount()
+}
@@ -2,56 +2,151 @@ package picture
 
 import (
 	"fmt"
-	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hultan/evolvingImage/apt"
 )
 
-const (
-	imageComplexity    = 25
-	imageMinComplexity = 5
-	nodes              = imageComplexity
-)
-
 type Picture struct {
 	R, G, B apt.Node
 }
 
 func NewPicture() *Picture {
-	p := &Picture{}
+	return defaultBreeder().NewPicture()
+}
 
-	// Generate image
-	p.R = p.newNode()
-	p.G = p.newNode()
-	p.B = p.newNode()
+// Complexity is the range of the number of operators that are added to
+// each tree of a picture created by NewPicture, or by the random init
+// method.
+type Complexity struct {
+	Min, Max int
+}
+
+var complexity = Complexity{
+	Min: 5,
+	Max: 29,
+}
 
-	return p
+// SetComplexity sets the complexity of the pictures created by NewPicture
+// and NewPopulation. Breeders have their own complexity.
+func SetComplexity(c Complexity) {
+	complexity = c
 }
 
-func (p *Picture) newNode() apt.Node {
+// GetComplexity returns the complexity of new pictures.
+func GetComplexity() Complexity {
+	return complexity
+}
+
+func (b *Breeder) newRandomNode() apt.Node {
 	// Generate image
-	node := apt.GetRandomNode()
+	node := b.Generator.RandomNode()
 
-	num := rand.Intn(nodes) + imageMinComplexity
+	num := b.Generator.Rand().Intn(max(b.Complexity.Max-b.Complexity.Min, 0)+1) + b.Complexity.Min
 	for i := 0; i < num; i++ {
-		node.AddRandom(apt.GetRandomNode())
+		b.Generator.AddRandom(node, b.Generator.RandomNode())
 	}
 
-	for node.AddLeaf(apt.GetRandomLeafNode()) {
+	for node.AddLeaf(b.Generator.RandomLeafNode()) {
 	}
 
-	return node
+	return b.Limits.repair(b.Generator, node)
+}
+
+// Load reads a picture from an .apt file.
+func Load(fileName string) (*Picture, error) {
+	p, _, err := LoadWithHeader(fileName)
+	return p, err
+}
+
+// LoadWithHeader reads a picture and the header of an .apt file.
+func LoadWithHeader(fileName string) (*Picture, apt.Header, error) {
+	bytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, apt.Header{}, err
+	}
+	header, p, err := parseFile(string(bytes))
+	if err != nil {
+		return nil, apt.Header{}, fmt.Errorf("%s : %w", fileName, err)
+	}
+	return p, header, nil
+}
+
+// Parse parses a picture in the .apt format.
+func Parse(s string) (*Picture, error) {
+	_, p, err := parseFile(s)
+	return p, err
+}
+
+func parseFile(s string) (apt.Header, *Picture, error) {
+	header, node, err := apt.ParseFile(s)
+	if err != nil {
+		return apt.Header{}, nil, err
+	}
+	p, err := FromNode(node)
+	return header, p, err
+}
+
+// NewHeader returns a header for an .apt file, with the color model and the creation time.
+func NewHeader() apt.Header {
+	header := apt.NewHeader()
+	header.Set("colors", "rgb")
+	header.Set("created", time.Now().UTC().Format(time.RFC3339))
+	return header
+}
+
+// FromNode creates a picture from the root node of a parsed .apt file.
+func FromNode(node apt.Node) (*Picture, error) {
+	if _, ok := node.(*apt.OperatorPicture); !ok {
+		return nil, fmt.Errorf("expected a Picture, got %s", node.String())
+	}
+
+	children := node.GetChildren()
+	for _, child := range children {
+		child.SetParent(nil)
+	}
+	return &Picture{
+		R: children[0],
+		G: children[1],
+		B: children[2],
+	}, nil
 }
 
 func (p *Picture) String() string {
 	return "( Picture \n" + p.R.String() + " \n" + p.G.String() + " \n" + p.B.String() + " \n)"
 }
 
+// Format returns the picture as indented text (see apt.Format).
+func (p *Picture) Format(options apt.FormatOptions) string {
+	return apt.Format(p.node(), options)
+}
+
+// Diff returns the edits that change p into other (see apt.Diff). The
+// first index of each path is the channel : 0 for R, 1 for G and 2 for B.
+func (p *Picture) Diff(other *Picture) []apt.Edit {
+	return apt.Diff(p.node(), other.node())
+}
+
+// node returns a Picture node with the channels of p, which keep their parents.
+func (p *Picture) node() apt.Node {
+	node := apt.NewPicture()
+	node.Children = []apt.Node{p.R, p.G, p.B}
+	return node
+}
+
+// Mutate mutates a random node in one of the channels. Mutations that
+// break the limits are rejected, and if no allowed mutation is found
+// the picture is left unchanged.
 func (p *Picture) Mutate() {
-	r := rand.Intn(3)
+	defaultBreeder().Mutate(p)
+}
+
+func (p *Picture) mutate(b *Breeder) {
+	r := b.Generator.Rand().Intn(3)
 	var nodeToMutate apt.Node
 
 	switch r {
@@ -66,11 +161,11 @@ func (p *Picture) Mutate() {
 	}
 
 	count := nodeToMutate.NodeCount()
-	r = rand.Intn(count)
+	r = b.Generator.Rand().Intn(count)
 	nodeToMutate, count = apt.GetNthNode(nodeToMutate, r, 0)
 	// If the node that we mutated is one of the root nodes
 	// we need to handle that.
-	mutation := apt.Mutate(nodeToMutate)
+	mutation := b.Generator.Mutate(nodeToMutate)
 	if mutation == p.R {
 		p.R = mutation
 	} else if mutation == p.G {
@@ -80,8 +175,24 @@ func (p *Picture) Mutate() {
 	}
 }
 
+// Save saves the picture with a new header (see NewHeader), to the
+// next free numbered .apt file in the current directory.
 func (p *Picture) Save() {
-	files, err := os.ReadDir("./")
+	p.SaveWithHeader(NewHeader())
+}
+
+// SaveWithHeader saves the picture like Save, with the given header.
+func (p *Picture) SaveWithHeader(header apt.Header) {
+	p.SaveInDirectory(".", header)
+}
+
+// SaveInDirectory saves the picture like SaveWithHeader, to the next free
+// numbered .apt file in dir, which is created if it doesn't exist.
+func (p *Picture) SaveInDirectory(dir string, header apt.Header) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(err)
+	}
+	files, err := os.ReadDir(dir)
 	if err != nil {
 		panic(err)
 	}
@@ -101,31 +212,46 @@ func (p *Picture) Save() {
 		}
 	}
 	name := fmt.Sprintf("%d.apt", biggest+1)
-	file, err := os.Create(name)
+	file, err := os.Create(filepath.Join(dir, name))
 	if err != nil {
 		panic(err)
 	}
 	defer file.Close()
 
-	_, err = fmt.Fprintf(file, p.String())
+	_, err = fmt.Fprint(file, p.Format(apt.DefaultFormatOptions)+header.String())
 	if err != nil {
 		panic(err)
 	}
 }
 
+// WriteFile writes the picture to an .apt file, with a new header (see NewHeader).
+func (p *Picture) WriteFile(fileName string) error {
+	return p.WriteFileWithHeader(fileName, NewHeader())
+}
+
+// WriteFileWithHeader writes the picture to an .apt file, with the given header.
+func (p *Picture) WriteFileWithHeader(fileName string, header apt.Header) error {
+	return os.WriteFile(fileName, []byte(p.Format(apt.DefaultFormatOptions)+header.String()), 0644)
+}
+
+// Cross grafts a random subtree of other into a copy of p.
 func (p *Picture) Cross(other *Picture) *Picture {
+	return p.CrossWith(other, CrossoverSubtree)
+}
+
+func (p *Picture) crossSubtree(r apt.Source, other *Picture) *Picture {
 	aCopy := &Picture{
 		apt.CopyTree(p.R, nil),
 		apt.CopyTree(p.G, nil),
 		apt.CopyTree(p.B, nil),
 	}
-	aColor := aCopy.pickRandomColor()
-	bColor := other.pickRandomColor()
+	aColor := aCopy.pickRandomColor(r)
+	bColor := other.pickRandomColor(r)
 
-	aIndex := rand.Intn(aColor.NodeCount())
+	aIndex := r.Intn(aColor.NodeCount())
 	aNode, _ := apt.GetNthNode(aColor, aIndex, 0)
 
-	bIndex := rand.Intn(bColor.NodeCount())
+	bIndex := r.Intn(bColor.NodeCount())
 	bNode, _ := apt.GetNthNode(bColor, bIndex, 0)
 	bNodeCopy := apt.CopyTree(bNode, bNode.GetParent())
 
@@ -133,9 +259,8 @@ func (p *Picture) Cross(other *Picture) *Picture {
 	return aCopy
 }
 
-func (p *Picture) pickRandomColor() apt.Node {
-	r := rand.Intn(3)
-	switch r {
+func (p *Picture) pickRandomColor(r apt.Source) apt.Node {
+	switch r.Intn(3) {
 	case 0:
 		return p.R
 	case 1:
@@ -146,3 +271,12 @@ func (p *Picture) pickRandomColor() apt.Node {
 		panic("PickRandomColor : Should not happen!")
 	}
 }
+
+// Simplify returns a simplified copy of the picture, that renders identically (see apt.Simplify).
+func (p *Picture) Simplify() *Picture {
+	return &Picture{
+		R: apt.Simplify(p.R),
+		G: apt.Simplify(p.G),
+		B: apt.Simplify(p.B),
+	}
+}
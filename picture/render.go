@@ -0,0 +1,74 @@
+package picture
+
+import (
+	"image"
+	"math"
+
+	"github.com/hultan/evolvingImage/apt"
+)
+
+// Coordinates returns the x (or y) coordinates, in [-1, 1), of each
+// pixel when a picture is rendered size pixels wide (or high).
+func Coordinates(size int) []float64 {
+	coordinates := make([]float64, size)
+	for i := range coordinates {
+		coordinates[i] = float64(i)/float64(size)*2 - 1
+	}
+	return coordinates
+}
+
+// Evaluate evaluates the three channels of the picture on a width x height
+// grid, and returns the values of each channel row by row.
+func (p *Picture) Evaluate(width, height int) (r, g, b []float64) {
+	xs := Coordinates(width)
+	ys := Coordinates(height)
+
+	r = make([]float64, width*height)
+	g = make([]float64, width*height)
+	b = make([]float64, width*height)
+	apt.EvaluateGrid(p.R, xs, ys, r)
+	apt.EvaluateGrid(p.G, xs, ys, g)
+	apt.EvaluateGrid(p.B, xs, ys, b)
+	return r, g, b
+}
+
+// Pixels renders the picture into RGBA pixels, row by row.
+func (p *Picture) Pixels(width, height int) []byte {
+	return p.PixelsIn(ColorsRGB, width, height)
+}
+
+// PixelsIn renders the picture into RGBA pixels, row by row, using the
+// channels as the given color model.
+func (p *Picture) PixelsIn(colors ColorModel, width, height int) []byte {
+	r, g, b := p.Evaluate(width, height)
+
+	pixels := make([]byte, width*height*4)
+	for i := range r {
+		pixels[i*4+0], pixels[i*4+1], pixels[i*4+2] = colors.color(r[i], g[i], b[i])
+		pixels[i*4+3] = 255
+	}
+	return pixels
+}
+
+// Image renders the picture into an image.
+func (p *Picture) Image(width, height int) *image.RGBA {
+	return &image.RGBA{
+		Pix:    p.Pixels(width, height),
+		Stride: width * 4,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+}
+
+// ToByte converts the value of a channel, nominally in [-1, 1], to a color component.
+func ToByte(value float64) byte {
+	scale := 128.0
+	offset := -1 * scale
+	v := value*scale - offset
+	// Go leaves the conversion of NaN and out of range values to integers
+	// to the platform, so it is done explicitly, the way amd64 does it,
+	// to get the same pixels on all platforms (like wasm)
+	if !(v > math.MinInt32-1 && v < math.MaxInt32+1) {
+		return 0
+	}
+	return byte(int32(v))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hultan/evolvingImage/picture"
+)
+
+// sessionVersion is the version of the session file format.
+const sessionVersion = 1
+
+// autosaveFile is where the session is saved on exit, in the output
+// directory, if no session file was given.
+const autosaveFile = "autosave.session"
+
+// session is everything needed to continue evolving where the user left
+// off : the pictures and which of them are selected, the generation, the
+// seed and the settings. The novelty archive is not saved.
+type session struct {
+	Version    int              `json:"version"`
+	Seed       int64            `json:"seed"`
+	Generation int              `json:"generation"`
+	Settings   sessionSettings  `json:"settings"`
+	Pictures   []sessionPicture `json:"pictures"`
+}
+
+type sessionSettings struct {
+	MutationRate int                   `json:"mutationRate"`
+	Crossover    picture.CrossoverMode `json:"crossover"`
+	MaxDepth     int                   `json:"maxDepth"`
+	MaxNodes     int                   `json:"maxNodes"`
+	Init         picture.InitMethod    `json:"init"`
+	InitMinDepth int                   `json:"initMinDepth"`
+	InitMaxDepth int                   `json:"initMaxDepth"`
+	Simplify     bool                  `json:"simplify"`
+	Dedupe       bool                  `json:"dedupe"`
+	Phenotype    bool                  `json:"phenotype"`
+	Novelty      bool                  `json:"novelty"`
+	Candidates   int                   `json:"candidates"`
+	// ComplexityMax is 0 in sessions from before the complexity was saved
+	ComplexityMin int                `json:"complexityMin"`
+	ComplexityMax int                `json:"complexityMax"`
+	Colors        picture.ColorModel `json:"colors"`
+	Rows          int32              `json:"rows,omitempty"`
+	Cols          int32              `json:"cols,omitempty"`
+}
+
+type sessionPicture struct {
+	Tree     string `json:"tree"`
+	Selected bool   `json:"selected,omitempty"`
+}
+
+// pendingSelection is the selection of a resumed session, which is applied
+// to the buttons when they are created.
+var pendingSelection []bool
+
+// saveSession saves the current session to a file.
+func saveSession(fileName string) error {
+	s := session{
+		Version:    sessionVersion,
+		Seed:       config.seed,
+		Generation: generation,
+		Settings: sessionSettings{
+			MutationRate:  config.mutationRate,
+			Crossover:     config.crossover,
+			MaxDepth:      config.limits.MaxDepth,
+			MaxNodes:      config.limits.MaxNodes,
+			Init:          config.init,
+			InitMinDepth:  config.initMinDepth,
+			InitMaxDepth:  config.initMaxDepth,
+			Simplify:      config.simplify,
+			Dedupe:        config.dedupe,
+			Phenotype:     config.phenotype,
+			Novelty:       config.novelty,
+			Candidates:    config.candidates,
+			ComplexityMin: config.complexity.Min,
+			ComplexityMax: config.complexity.Max,
+			Colors:        config.colors,
+			Rows:          rows,
+			Cols:          cols,
+		},
+	}
+	for i, p := range pictures {
+		s.Pictures = append(s.Pictures, sessionPicture{
+			Tree:     p.String(),
+			Selected: isSelected(i),
+		})
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// isSelected returns true if picture i is selected, or will be selected
+// once its button is created.
+func isSelected(i int) bool {
+	if buttons[i] != nil {
+		return buttons[i].Selected
+	}
+	return pendingSelection != nil && pendingSelection[i]
+}
+
+// selection returns which pictures are selected.
+func selection() []bool {
+	selected := make([]bool, len(pictures))
+	for i := range selected {
+		selected[i] = isSelected(i)
+	}
+	return selected
+}
+
+// loadSession reads a session file, and restores its seed, generation and settings.
+// The pictures are returned, and are shown by the caller.
+func loadSession(fileName string) ([]*picture.Picture, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var s session
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s : %w", fileName, err)
+	}
+	if s.Version != sessionVersion {
+		return nil, fmt.Errorf("%s : unsupported session version %d", fileName, s.Version)
+	}
+	if len(s.Pictures) == 0 {
+		return nil, fmt.Errorf("%s : the session has no pictures", fileName)
+	}
+	if s.Settings.MutationRate < 1 {
+		return nil, fmt.Errorf("%s : invalid mutation rate %d", fileName, s.Settings.MutationRate)
+	}
+
+	pics := make([]*picture.Picture, len(s.Pictures))
+	selection := make([]bool, len(s.Pictures))
+	for i, sp := range s.Pictures {
+		if pics[i], err = picture.Parse(sp.Tree); err != nil {
+			return nil, fmt.Errorf("%s : picture %d : %w", fileName, i+1, err)
+		}
+		selection[i] = sp.Selected
+	}
+
+	// Flags given on the command line override the settings of the session
+	restore := func(name string, set func()) {
+		if !commandLine[name] {
+			set()
+		}
+	}
+	restore("seed", func() { config.seed = s.Seed })
+	restore("mutation", func() { config.mutationRate = s.Settings.MutationRate })
+	restore("crossover", func() { config.crossover = s.Settings.Crossover })
+	restore("maxdepth", func() { config.limits.MaxDepth = s.Settings.MaxDepth })
+	restore("maxnodes", func() { config.limits.MaxNodes = s.Settings.MaxNodes })
+	restore("init", func() { config.init = s.Settings.Init })
+	restore("initmin", func() { config.initMinDepth = s.Settings.InitMinDepth })
+	restore("initmax", func() { config.initMaxDepth = s.Settings.InitMaxDepth })
+	restore("simplify", func() { config.simplify = s.Settings.Simplify })
+	restore("dedupe", func() { config.dedupe = s.Settings.Dedupe })
+	restore("phenotype", func() { config.phenotype = s.Settings.Phenotype })
+	restore("novelty", func() { config.novelty = s.Settings.Novelty })
+	restore("candidates", func() { config.candidates = s.Settings.Candidates })
+	if s.Settings.ComplexityMax > 0 {
+		restore("complexitymin", func() { config.complexity.Min = s.Settings.ComplexityMin })
+		restore("complexitymax", func() { config.complexity.Max = s.Settings.ComplexityMax })
+	}
+	restore("colors", func() { config.colors = s.Settings.Colors })
+	if s.Settings.Rows > 0 && s.Settings.Cols > 0 {
+		restore("rows", func() { rows = s.Settings.Rows })
+		restore("cols", func() { cols = s.Settings.Cols })
+	}
+	restore("population", func() { numPics = int32(len(s.Pictures)) })
+	generation = s.Generation
+	pendingSelection = selection
+	return pics, nil
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+const (
+	settingsFontSize  = 20
+	settingsRowHeight = 34
+	// settingsMaxWeight is the largest operator weight the sliders go to
+	settingsMaxWeight = 5
+)
+
+// setting is a row of the settings panel. Numbers are shown as sliders,
+// and settings with choices as toggles.
+type setting struct {
+	label    string
+	min, max float64
+	step     float64
+	choices  []string // the names of the values 0, 1... of a choice
+	get      func() float64
+	set      func(v float64)
+}
+
+// settingsView is an overlay in select mode, where the settings of the
+// evolution are changed. The changes are used from the next generation
+// on, and are saved in the user config directory when the panel is
+// closed, so that the next launch starts with them.
+type settingsView struct {
+	settings []setting // the general settings, then the operator weights
+	general  int       // number of general settings, in the left column
+	selected int
+	scroll   int // first operator weight shown
+	dragging int // setting whose slider is dragged, or -1
+	colors   picture.ColorModel
+	message  string
+}
+
+var settingsPanel *settingsView
+
+// defaultOperators and defaultSettings are the operators and the general
+// settings before the settings of the panel are loaded, so that only the
+// ones that are changed in the panel are saved, and the config file still
+// sets the others.
+var (
+	defaultOperators []apt.OperatorSpec
+	defaultSettings  [][2]string
+)
+
+// settingsFileName returns the file that the settings panel saves to.
+func settingsFileName() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "evolvingImage", "settings.conf"), nil
+}
+
+func openSettings() {
+	s := &settingsView{
+		dragging: -1,
+		colors:   config.colors,
+	}
+	var colorNames []string
+	for model := picture.ColorsRGB; model <= picture.ColorsGray; model++ {
+		colorNames = append(colorNames, model.String())
+	}
+
+	s.settings = []setting{
+		{
+			label: "Mutation rate", min: 1, max: 50, step: 1,
+			get: func() float64 { return float64(config.mutationRate) },
+			set: func(v float64) { config.mutationRate = int(v) },
+		},
+		{
+			label: "Min complexity", min: 0, max: 100, step: 1,
+			get: func() float64 { return float64(config.complexity.Min) },
+			set: func(v float64) {
+				config.complexity.Min = int(v)
+				config.complexity.Max = max(config.complexity.Max, config.complexity.Min)
+				picture.SetComplexity(config.complexity)
+			},
+		},
+		{
+			label: "Max complexity", min: 0, max: 100, step: 1,
+			get: func() float64 { return float64(config.complexity.Max) },
+			set: func(v float64) {
+				config.complexity.Max = int(v)
+				config.complexity.Min = min(config.complexity.Min, config.complexity.Max)
+				picture.SetComplexity(config.complexity)
+			},
+		},
+		{
+			label: "Colors", choices: colorNames,
+			get: func() float64 { return float64(config.colors) },
+			set: func(v float64) { config.colors = picture.ColorModel(v) },
+		},
+		{
+			label: "Selection", choices: []string{"interactive", "novelty"},
+			get: func() float64 {
+				if config.novelty {
+					return 1
+				}
+				return 0
+			},
+			set: func(v float64) { config.novelty = v == 1 },
+		},
+	}
+	s.general = len(s.settings)
+
+	for _, spec := range apt.Operators() {
+		name, label := spec.Name, spec.Name
+		if spec.Arity == 0 {
+			label += " (leaf)"
+		}
+		s.settings = append(s.settings, setting{
+			label: label, min: 0, max: settingsMaxWeight, step: 0.25,
+			get: func() float64 { return operatorWeight(name) },
+			set: func(v float64) { s.setWeight(name, v) },
+		})
+	}
+
+	for i := range s.settings {
+		if s.settings[i].choices != nil {
+			s.settings[i].max = float64(len(s.settings[i].choices) - 1)
+			s.settings[i].step = 1
+		}
+	}
+	settingsPanel = s
+}
+
+// closeSettings saves the settings, and renders the pictures again if
+// the color model was changed.
+func closeSettings() {
+	colors := settingsPanel.colors
+	settingsPanel = nil
+
+	fileName, err := settingsFileName()
+	if err == nil {
+		err = saveSettings(fileName)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if config.colors != colors {
+		relayout()
+	}
+}
+
+// settingValues returns the general settings of the panel, as the names
+// and values of their flags.
+func settingValues() [][2]string {
+	return [][2]string{
+		{"mutation", strconv.Itoa(config.mutationRate)},
+		{"complexitymin", strconv.Itoa(config.complexity.Min)},
+		{"complexitymax", strconv.Itoa(config.complexity.Max)},
+		{"colors", config.colors.String()},
+		{"novelty", strconv.FormatBool(config.novelty)},
+	}
+}
+
+// saveSettings saves the settings of the panel in the format of loadConfig.
+func saveSettings(fileName string) error {
+	var sb strings.Builder
+	sb.WriteString("# Saved by the settings panel\n")
+	for i, value := range settingValues() {
+		if i >= len(defaultSettings) || value != defaultSettings[i] {
+			fmt.Fprintf(&sb, "%s = %s\n", value[0], value[1])
+		}
+	}
+
+	defaults := make(map[string]apt.OperatorSpec)
+	for _, spec := range defaultOperators {
+		defaults[spec.Name] = spec
+	}
+	for _, spec := range apt.Operators() {
+		original, ok := defaults[spec.Name]
+		switch {
+		case !spec.Enabled:
+			if !ok || original.Enabled {
+				fmt.Fprintf(&sb, "weight.%s = off\n", spec.Name)
+			}
+			continue
+		case ok && !original.Enabled:
+			fmt.Fprintf(&sb, "weight.%s = on\n", spec.Name)
+		}
+		if !ok || spec.Weight != original.Weight {
+			fmt.Fprintf(&sb, "weight.%s = %s\n", spec.Name, strconv.FormatFloat(spec.Weight, 'g', -1, 64))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, []byte(sb.String()), 0644)
+}
+
+// operatorWeight returns the weight of an operator, or 0 if it is off.
+func operatorWeight(name string) float64 {
+	spec, _ := apt.LookupOperator(name)
+	if !spec.Enabled {
+		return 0
+	}
+	return spec.Weight
+}
+
+// setWeight sets the weight of an operator, and turns it on. The last leaf
+// or operator with a weight can't be set to 0, since no trees could be
+// built without it.
+func (s *settingsView) setWeight(name string, weight float64) {
+	spec, _ := apt.LookupOperator(name)
+	if weight == 0 {
+		others := 0.0
+		for _, other := range apt.Operators() {
+			if other.Name != name && other.Enabled && (other.Arity == 0) == (spec.Arity == 0) {
+				others += other.Weight
+			}
+		}
+		if others == 0 {
+			s.message = fmt.Sprintf("%s is the last one with a weight", name)
+			return
+		}
+	}
+	apt.SetEnabled(name, true)
+	apt.SetWeight(name, weight)
+	s.message = ""
+}
+
+// setValue sets setting i to v, rounded to its step and kept in its range.
+func (s *settingsView) setValue(i int, v float64) {
+	setting := s.settings[i]
+	v = math.Round((v-setting.min)/setting.step)*setting.step + setting.min
+	v = min(max(v, setting.min), setting.max)
+	if v != setting.get() {
+		setting.set(v)
+	}
+}
+
+// panel returns the rectangle of the settings panel, in the middle of the screen.
+func (s *settingsView) panel() rl.Rectangle {
+	width := min(float32(screenWidth)-100, 1100)
+	return rl.Rectangle{
+		X:      (float32(screenWidth) - width) / 2,
+		Y:      40,
+		Width:  width,
+		Height: float32(screenHeight)*0.9 - 60,
+	}
+}
+
+// visibleWeights returns how many operator weights fit in the panel.
+func (s *settingsView) visibleWeights() int {
+	return max(int(s.panel().Height-120)/settingsRowHeight, 1)
+}
+
+// row returns the rectangle of setting i, and false if it is not shown.
+func (s *settingsView) row(i int) (rl.Rectangle, bool) {
+	panel := s.panel()
+	column := panel.Width/2 - 30
+	rect := rl.Rectangle{X: panel.X + 20, Y: panel.Y + 70, Width: column, Height: settingsRowHeight}
+	if i < s.general {
+		rect.Y += float32(i * settingsRowHeight)
+		return rect, true
+	}
+
+	i -= s.general
+	rect.X += column + 40
+	rect.Y += float32((i - s.scroll) * settingsRowHeight)
+	return rect, i >= s.scroll && i < s.scroll+s.visibleWeights()
+}
+
+// control returns the rectangle of the slider or toggles in a row.
+func control(row rl.Rectangle) rl.Rectangle {
+	return rl.Rectangle{
+		X:      row.X + row.Width*0.45,
+		Y:      row.Y + 6,
+		Width:  row.Width*0.55 - 70,
+		Height: row.Height - 12,
+	}
+}
+
+func (s *settingsView) update() {
+	if rl.IsKeyPressed(keys.settings) {
+		closeSettings()
+		return
+	}
+
+	switch {
+	case isKeyPressed(rl.KeyUp):
+		s.selected = max(s.selected-1, 0)
+	case isKeyPressed(rl.KeyDown):
+		s.selected = min(s.selected+1, len(s.settings)-1)
+	case isKeyPressed(rl.KeyLeft):
+		s.setValue(s.selected, s.settings[s.selected].get()-s.settings[s.selected].step)
+	case isKeyPressed(rl.KeyRight):
+		s.setValue(s.selected, s.settings[s.selected].get()+s.settings[s.selected].step)
+	}
+
+	mouse := rl.GetMousePosition()
+	if rl.IsMouseButtonPressed(rl.MouseButtonLeft) {
+		for i := range s.settings {
+			if row, ok := s.row(i); ok && rl.CheckCollisionPointRec(mouse, row) {
+				s.selected = i
+				if rl.CheckCollisionPointRec(mouse, control(row)) {
+					s.dragging = i
+				}
+			}
+		}
+	}
+	if s.dragging >= 0 && rl.IsMouseButtonDown(rl.MouseButtonLeft) {
+		row, _ := s.row(s.dragging)
+		c := control(row)
+		fraction := float64(min(max((mouse.X-c.X)/c.Width, 0), 1))
+		setting := s.settings[s.dragging]
+		if setting.choices != nil {
+			// Each choice has a toggle of the same width
+			s.setValue(s.dragging, min(math.Floor(fraction*float64(len(setting.choices))), setting.max))
+		} else {
+			s.setValue(s.dragging, setting.min+fraction*(setting.max-setting.min))
+		}
+	} else {
+		s.dragging = -1
+	}
+
+	visible := s.visibleWeights()
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 {
+		s.scroll -= int(wheel) * 3
+	} else if s.selected >= s.general {
+		// Keep the selected weight visible
+		s.scroll = min(s.scroll, s.selected-s.general)
+		s.scroll = max(s.scroll, s.selected-s.general-visible+1)
+	}
+	s.scroll = max(min(s.scroll, len(s.settings)-s.general-visible), 0)
+}
+
+func (s *settingsView) draw() {
+	panel := s.panel()
+	rl.DrawRectangleRec(panel, rl.Fade(rl.Black, 0.9))
+	rl.DrawRectangleLinesEx(panel, 1, rl.Gray)
+	rl.DrawText("Settings", int32(panel.X)+20, int32(panel.Y)+15, 28, rl.White)
+
+	first, _ := s.row(0)
+	rl.DrawText("Evolution", int32(first.X), int32(first.Y)-26, settingsFontSize, rl.Gray)
+	rl.DrawText("Operator weights", int32(first.X+first.Width+40), int32(first.Y)-26, settingsFontSize, rl.Gray)
+
+	for i, setting := range s.settings {
+		row, ok := s.row(i)
+		if !ok {
+			continue
+		}
+		if i == s.selected {
+			rl.DrawRectangleRec(row, rl.DarkGray)
+		}
+		y := int32(row.Y) + (settingsRowHeight-settingsFontSize)/2
+		rl.DrawText(setting.label, int32(row.X)+6, y, settingsFontSize, rl.LightGray)
+
+		c := control(row)
+		value := setting.get()
+		if setting.choices != nil {
+			width := c.Width / float32(len(setting.choices))
+			for k, choice := range setting.choices {
+				toggle := rl.Rectangle{X: c.X + float32(k)*width, Y: c.Y, Width: width - 2, Height: c.Height}
+				color, textColor := rl.Fade(rl.Gray, 0.3), rl.LightGray
+				if float64(k) == value {
+					color, textColor = rl.LightGray, rl.Black
+				}
+				rl.DrawRectangleRec(toggle, color)
+				textX := int32(toggle.X+toggle.Width/2) - rl.MeasureText(choice, settingsFontSize-4)/2
+				rl.DrawText(choice, textX, int32(toggle.Y)+2, settingsFontSize-4, textColor)
+			}
+			continue
+		}
+
+		fraction := float32((value - setting.min) / (setting.max - setting.min))
+		track := rl.Rectangle{X: c.X, Y: c.Y + c.Height/2 - 2, Width: c.Width, Height: 4}
+		rl.DrawRectangleRec(track, rl.Gray)
+		rl.DrawRectangleRec(rl.Rectangle{X: track.X, Y: track.Y, Width: track.Width * fraction, Height: 4}, rl.SkyBlue)
+		rl.DrawRectangleRec(rl.Rectangle{X: c.X + c.Width*fraction - 5, Y: c.Y, Width: 10, Height: c.Height}, rl.White)
+		rl.DrawText(strconv.FormatFloat(value, 'f', -1, 64), int32(c.X+c.Width)+12, y, settingsFontSize, rl.LightGray)
+	}
+
+	y := int32(panel.Y+panel.Height) - 34
+	if s.message != "" {
+		rl.DrawText(s.message, int32(panel.X)+20, y, settingsFontSize, rl.Orange)
+	} else {
+		rl.DrawText("Up/Down : select, Left/Right or the mouse : change, "+keyName(keys.settings)+" : save and close",
+			int32(panel.X)+20, y, settingsFontSize, rl.Gray)
+	}
+}
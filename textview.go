@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/hultan/evolvingImage/apt"
+	"github.com/hultan/evolvingImage/picture"
+)
+
+const (
+	textFontSize   = 20
+	textLineHeight = 22
+	// textWidth is the width of the formatted text (see apt.Format).
+	textWidth = 48
+	// textRenderDelay is how long the text must be left alone before the
+	// picture is rendered again, so that it isn't rendered on every key.
+	textRenderDelay = 300 * time.Millisecond
+)
+
+// textView is a panel in zoom mode, where the picture can be edited as
+// text. The text is parsed after every change, errors are shown where
+// they are, and the picture is rendered again when the text is valid.
+type textView struct {
+	lines    [][]rune
+	row, col int // of the cursor
+	scroll   int // first line shown
+
+	original *picture.Picture
+	index    int              // index of the original in pictures, or -1
+	picture  *picture.Picture // the last valid picture
+	err      error
+	position *apt.ParseError // of err, if it has one
+
+	changedAt time.Time
+	dirty     bool // true if the picture is not rendered yet
+	version   int  // of the picture, counting the valid changes
+	rendered  int  // version of the picture in the zoom image
+	images    previewSlot
+}
+
+var textPanel *textView
+
+// openTextView shows the zoomed in picture as text.
+func openTextView() {
+	textPanel = &textView{
+		original: state.zoomTree,
+		index:    state.zoomIndex,
+		picture:  state.zoomTree,
+	}
+	options := apt.DefaultFormatOptions
+	options.Width = textWidth
+	src := strings.TrimSuffix(state.zoomTree.Format(options), "\n")
+	for _, line := range strings.Split(src, "\n") {
+		textPanel.lines = append(textPanel.lines, []rune(line))
+	}
+}
+
+// closeTextView hides the text, and puts the last valid picture in the
+// place of the original in the current generation.
+func closeTextView() {
+	if textPanel.picture != textPanel.original {
+		state.zoomIndex = replacePicture(textPanel.index, textPanel.original, textPanel.picture)
+	}
+	textPanel = nil
+}
+
+func (t *textView) String() string {
+	lines := make([]string, len(t.lines))
+	for i, line := range t.lines {
+		lines[i] = string(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// changed parses the text after a change.
+func (t *textView) changed() {
+	p, err := picture.Parse(t.String())
+	t.err = err
+	t.position = nil
+	errors.As(err, &t.position)
+	if err == nil {
+		t.picture = p
+		t.version++
+		t.dirty = true
+		t.changedAt = time.Now()
+		state.zoomTree = p
+	}
+}
+
+func (t *textView) insert(r rune) {
+	line := t.lines[t.row]
+	t.lines[t.row] = append(line[:t.col:t.col], append([]rune{r}, line[t.col:]...)...)
+	t.col++
+}
+
+// newLine splits the line at the cursor, and indents the new line like
+// the current one.
+func (t *textView) newLine() {
+	line := t.lines[t.row]
+	indent := 0
+	for indent < len(line) && indent < t.col && line[indent] == ' ' {
+		indent++
+	}
+	rest := append([]rune(strings.Repeat(" ", indent)), line[t.col:]...)
+	t.lines[t.row] = line[:t.col:t.col]
+	t.lines = append(t.lines[:t.row+1], append([][]rune{rest}, t.lines[t.row+1:]...)...)
+	t.row++
+	t.col = indent
+}
+
+func (t *textView) backspace() {
+	if t.col > 0 {
+		line := t.lines[t.row]
+		t.lines[t.row] = append(line[:t.col-1:t.col-1], line[t.col:]...)
+		t.col--
+	} else if t.row > 0 {
+		t.col = len(t.lines[t.row-1])
+		t.lines[t.row-1] = append(t.lines[t.row-1], t.lines[t.row]...)
+		t.lines = append(t.lines[:t.row], t.lines[t.row+1:]...)
+		t.row--
+	}
+}
+
+func (t *textView) delete() {
+	if t.col < len(t.lines[t.row]) {
+		t.col++
+		t.backspace()
+	} else if t.row < len(t.lines)-1 {
+		t.row++
+		t.col = 0
+		t.backspace()
+	}
+}
+
+// panel returns the rectangle of the text panel, on the right side of the picture.
+func (t *textView) panel() rl.Rectangle {
+	x := float32(screenWidth) * 0.45
+	return rl.Rectangle{X: x, Y: 0, Width: float32(screenWidth) - x, Height: float32(screenHeight) * 0.9}
+}
+
+// visibleLines returns how many lines fit in the panel, leaving a line for errors.
+func (t *textView) visibleLines() int {
+	return max(int(t.panel().Height)/textLineHeight-2, 1)
+}
+
+func (t *textView) update() {
+	if rl.IsKeyPressed(keys.text) {
+		p, rendered := t.picture, t.rendered == t.version
+		closeTextView()
+		if !rendered {
+			zoomIn(p, state.zoomIndex)
+		}
+		return
+	}
+
+	if result := t.images.take(); result != nil && result.version == t.version {
+		rl.UnloadTexture(state.zoomImage)
+		state.zoomImage = rl.LoadTextureFromImage(result.image)
+		t.rendered = result.version
+	}
+
+	edited := false
+	for r := rl.GetCharPressed(); r != 0; r = rl.GetCharPressed() {
+		t.insert(r)
+		edited = true
+	}
+	switch {
+	case isKeyPressed(rl.KeyEnter):
+		t.newLine()
+		edited = true
+	case isKeyPressed(rl.KeyBackspace):
+		t.backspace()
+		edited = true
+	case isKeyPressed(rl.KeyDelete):
+		t.delete()
+		edited = true
+	case isKeyPressed(rl.KeyTab):
+		t.insert(' ')
+		t.insert(' ')
+		edited = true
+	case isKeyPressed(rl.KeyLeft):
+		if t.col > 0 {
+			t.col--
+		} else if t.row > 0 {
+			t.row--
+			t.col = len(t.lines[t.row])
+		}
+	case isKeyPressed(rl.KeyRight):
+		if t.col < len(t.lines[t.row]) {
+			t.col++
+		} else if t.row < len(t.lines)-1 {
+			t.row++
+			t.col = 0
+		}
+	case isKeyPressed(rl.KeyUp):
+		t.row = max(t.row-1, 0)
+	case isKeyPressed(rl.KeyDown):
+		t.row = min(t.row+1, len(t.lines)-1)
+	case isKeyPressed(rl.KeyPageUp):
+		t.row = max(t.row-t.visibleLines(), 0)
+	case isKeyPressed(rl.KeyPageDown):
+		t.row = min(t.row+t.visibleLines(), len(t.lines)-1)
+	case rl.IsKeyPressed(rl.KeyHome):
+		t.col = 0
+	case rl.IsKeyPressed(rl.KeyEnd):
+		t.col = len(t.lines[t.row])
+	case rl.IsMouseButtonPressed(rl.MouseButtonLeft):
+		t.click(rl.GetMousePosition())
+	}
+	t.col = min(t.col, len(t.lines[t.row]))
+	if edited {
+		t.changed()
+	}
+
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 {
+		t.scroll -= int(wheel) * 3
+	} else {
+		// Keep the cursor visible
+		t.scroll = min(t.scroll, t.row)
+		t.scroll = max(t.scroll, t.row-t.visibleLines()+1)
+	}
+	t.scroll = max(min(t.scroll, len(t.lines)-t.visibleLines()), 0)
+
+	if t.dirty && time.Since(t.changedAt) > textRenderDelay {
+		t.dirty = false
+		p, version := t.picture, t.version
+		width, height := screenWidth, int32(float32(screenHeight)*0.9)
+		settings := currentRenderSettings()
+		go func() {
+			t.images.put(previewResult{newImage(p, width, height, settings), version})
+		}()
+	}
+}
+
+// click moves the cursor to the character closest to the mouse.
+func (t *textView) click(mouse rl.Vector2) {
+	panel := t.panel()
+	if !rl.CheckCollisionPointRec(mouse, panel) {
+		return
+	}
+	row := t.scroll + int(mouse.Y-panel.Y-4)/textLineHeight
+	if row >= len(t.lines) {
+		return
+	}
+	t.row = row
+	x := int32(mouse.X - panel.X - 10)
+	t.col = len(t.lines[row])
+	for col := range t.lines[row] {
+		if rl.MeasureText(string(t.lines[row][:col+1]), textFontSize) > x {
+			t.col = col
+			break
+		}
+	}
+}
+
+func (t *textView) draw() {
+	panel := t.panel()
+	rl.DrawRectangleRec(panel, rl.Fade(rl.Black, 0.8))
+	x0, y0 := int32(panel.X)+10, int32(panel.Y)+4
+
+	for i := t.scroll; i < len(t.lines) && i < t.scroll+t.visibleLines(); i++ {
+		y := y0 + int32(i-t.scroll)*textLineHeight
+		line := string(t.lines[i])
+		if t.position != nil && t.position.Line == i+1 {
+			rl.DrawRectangle(int32(panel.X), y-1, int32(panel.Width), textLineHeight, rl.Fade(rl.Red, 0.3))
+			column := min(t.position.Column-1, len(t.lines[i]))
+			x := x0 + rl.MeasureText(string(t.lines[i][:column]), textFontSize)
+			rl.DrawRectangle(x, y+textFontSize, max(rl.MeasureText(" ", textFontSize), 8), 2, rl.Red)
+		}
+		rl.DrawText(line, x0, y, textFontSize, rl.LightGray)
+		if i == t.row {
+			x := x0 + rl.MeasureText(string(t.lines[i][:t.col]), textFontSize)
+			rl.DrawRectangle(x, y, 2, textFontSize, rl.White)
+		}
+	}
+
+	y := int32(panel.Y+panel.Height) - textLineHeight - 4
+	if t.err != nil {
+		rl.DrawText(t.err.Error(), x0, y, textFontSize, rl.Red)
+	} else {
+		rl.DrawText(keyName(keys.text)+" : close the text", x0, y, textFontSize, rl.Gray)
+	}
+}